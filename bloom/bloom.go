@@ -0,0 +1,268 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+const (
+	// growth 每次扩容时新一层的容量倍数
+	growth = 2
+	// tightening 每一层的误判率是上一层的倍数, 保证总的误判率收敛于 fpRate
+	tightening = 0.8
+
+	formatVersion = 1
+	// maxHashes 每一层哈希函数个数的上限, 误判率为 1e-19 时也只需要约63个
+	maxHashes = 64
+)
+
+var ErrorInvalidData = errors.New("invalid bloom filter data")
+
+// Filter 可扩容的布隆过滤器(并发安全)
+// 元素数量超过当前层的容量时新增一层, 新层容量翻倍, 误判率按 tightening 收紧, 因此总误判率不超过创建时指定的 fpRate
+// Test 返回false时元素一定不存在, 返回true时元素可能存在
+type Filter struct {
+	mutex    sync.RWMutex
+	fpRate   float64
+	capacity uint64 // 第一层的容量
+	stages   []*stage
+}
+
+// stage 固定大小的布隆过滤器
+type stage struct {
+	bits     []uint64
+	m        uint64 // bit数
+	k        uint64 // 哈希函数个数
+	capacity uint64
+	count    uint64
+}
+
+// New 新建布隆过滤器
+// capacity 第一层的容量, 预计元素数量; fpRate 误判率, 取值范围 (0, 1)
+func New(capacity int, fpRate float64) *Filter {
+	if capacity <= 0 {
+		panic("capacity must be greater than 0")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		panic("false positive rate must be in (0, 1)")
+	}
+	f := &Filter{fpRate: fpRate, capacity: uint64(capacity)}
+	f.stages = append(f.stages, newStage(f.capacity, f.stageRate(0)))
+	return f
+}
+
+// stageRate 第i层的误判率, 各层误判率之和为 fpRate*(1-tightening)*(1+tightening+tightening^2+...) <= fpRate
+func (f *Filter) stageRate(i int) float64 {
+	return f.fpRate * (1 - tightening) * math.Pow(tightening, float64(i))
+}
+
+func newStage(capacity uint64, fpRate float64) *stage {
+	// m = -n*ln(p)/ln(2)^2, k = m/n*ln(2)
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &stage{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+func (s *stage) add(h1, h2 uint64) {
+	for i := uint64(0); i < s.k; i++ {
+		idx := (h1 + i*h2) % s.m
+		s.bits[idx/64] |= 1 << (idx % 64)
+	}
+	s.count++
+}
+
+func (s *stage) test(h1, h2 uint64) bool {
+	for i := uint64(0); i < s.k; i++ {
+		idx := (h1 + i*h2) % s.m
+		if s.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hash(data []byte) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write(data)
+	var sum [16]byte
+	h.Sum(sum[:0])
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// Add 添加元素
+func (f *Filter) Add(data []byte) {
+	h1, h2 := hash(data)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.add(h1, h2)
+}
+
+// AddString 添加字符串
+func (f *Filter) AddString(s string) {
+	f.Add([]byte(s))
+}
+
+func (f *Filter) add(h1, h2 uint64) {
+	last := f.stages[len(f.stages)-1]
+	if last.count >= last.capacity {
+		last = newStage(last.capacity*growth, f.stageRate(len(f.stages)))
+		f.stages = append(f.stages, last)
+	}
+	last.add(h1, h2)
+}
+
+// Test 元素是否可能存在
+func (f *Filter) Test(data []byte) bool {
+	h1, h2 := hash(data)
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.test(h1, h2)
+}
+
+// TestString 字符串是否可能存在
+func (f *Filter) TestString(s string) bool {
+	return f.Test([]byte(s))
+}
+
+func (f *Filter) test(h1, h2 uint64) bool {
+	for _, s := range f.stages {
+		if s.test(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAndAdd 返回元素添加之前是否可能存在, 并添加元素; 可能存在时不会重复添加
+func (f *Filter) TestAndAdd(data []byte) bool {
+	h1, h2 := hash(data)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.test(h1, h2) {
+		return true
+	}
+	f.add(h1, h2)
+	return false
+}
+
+// Count 添加过的元素数量
+func (f *Filter) Count() int {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	var count uint64
+	for _, s := range f.stages {
+		count += s.count
+	}
+	return int(count)
+}
+
+// Stages 当前的层数
+func (f *Filter) Stages() int {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return len(f.stages)
+}
+
+// MarshalBinary 序列化, 格式为 版本, 误判率, 第一层容量, 层数, 以及每一层的 m, k, 容量, 元素数量和bit数组, 均为大端序
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	size := 1 + 8*3
+	for _, s := range f.stages {
+		size += 8*4 + 8*len(s.bits)
+	}
+	data := make([]byte, 0, size)
+	data = append(data, formatVersion)
+	data = appendUint64(data, math.Float64bits(f.fpRate))
+	data = appendUint64(data, f.capacity)
+	data = appendUint64(data, uint64(len(f.stages)))
+	for _, s := range f.stages {
+		for _, n := range []uint64{s.m, s.k, s.capacity, s.count} {
+			data = appendUint64(data, n)
+		}
+		for _, word := range s.bits {
+			data = appendUint64(data, word)
+		}
+	}
+	return data, nil
+}
+
+// UnmarshalBinary 反序列化 MarshalBinary 的结果, 覆盖当前的内容
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	r := reader{data: data}
+	if r.byte() != formatVersion {
+		return ErrorInvalidData
+	}
+	fpRate := math.Float64frombits(r.uint64())
+	capacity := r.uint64()
+	n := r.uint64()
+	if r.err || fpRate <= 0 || fpRate >= 1 || capacity == 0 || n == 0 || n > uint64(len(data)) {
+		return ErrorInvalidData
+	}
+	stages := make([]*stage, 0, n)
+	for i := uint64(0); i < n; i++ {
+		s := &stage{m: r.uint64(), k: r.uint64(), capacity: r.uint64(), count: r.uint64()}
+		words := (s.m + 63) / 64
+		if r.err || s.m == 0 || s.k == 0 || s.k > maxHashes || s.capacity == 0 || s.count > s.capacity ||
+			words > uint64(len(r.data)/8) {
+			return ErrorInvalidData
+		}
+		s.bits = make([]uint64, words)
+		for j := range s.bits {
+			s.bits[j] = r.uint64()
+		}
+		stages = append(stages, s)
+	}
+	if r.err || len(r.data) != 0 {
+		return ErrorInvalidData
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.fpRate, f.capacity, f.stages = fpRate, capacity, stages
+	return nil
+}
+
+func appendUint64(data []byte, n uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	return append(data, buf[:]...)
+}
+
+type reader struct {
+	data []byte
+	err  bool
+}
+
+func (r *reader) byte() byte {
+	if len(r.data) < 1 {
+		r.err = true
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *reader) uint64() uint64 {
+	if len(r.data) < 8 {
+		r.err = true
+		return 0
+	}
+	n := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return n
+}
@@ -0,0 +1,113 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(1000, 0.01)
+	for i := 0; i < 10000; i++ {
+		f.AddString(fmt.Sprint("key", i))
+	}
+	if f.Count() != 10000 || f.Stages() < 2 {
+		t.Fatalf("count %d, stages %d", f.Count(), f.Stages())
+	}
+	for i := 0; i < 10000; i++ {
+		if !f.TestString(fmt.Sprint("key", i)) {
+			t.Fatalf("false negative for key%d", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.TestString(fmt.Sprint("absent", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.01 {
+		t.Fatalf("false positive rate %f exceeds 0.01", rate)
+	}
+}
+
+func TestTestAndAdd(t *testing.T) {
+	f := New(10, 0.01)
+	if f.TestAndAdd([]byte("a")) {
+		t.Fatal("a should be absent")
+	}
+	if !f.TestAndAdd([]byte("a")) || f.Count() != 1 {
+		t.Fatal("a should be present and added once")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	f := New(100, 0.001)
+	for i := 0; i < 500; i++ {
+		f.AddString(fmt.Sprint(i))
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g Filter
+	if err = g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if g.Count() != 500 || g.Stages() != f.Stages() {
+		t.Fatal("restored filter differs")
+	}
+	for i := 0; i < 500; i++ {
+		if !g.TestString(fmt.Sprint(i)) {
+			t.Fatal("false negative after unmarshal")
+		}
+	}
+	g.AddString("more")
+	if err = g.UnmarshalBinary(data[:len(data)-1]); err != ErrorInvalidData {
+		t.Fatalf("want ErrorInvalidData, got %v", err)
+	}
+}
+
+func TestUnmarshalCorrupted(t *testing.T) {
+	data, _ := New(10, 0.01).MarshalBinary()
+	// 第一层的 m, k, 容量, 元素数量 分别位于 25, 33, 41, 49
+	for _, c := range []struct {
+		name   string
+		offset int
+		value  uint64
+	}{
+		{"huge k", 33, 1 << 62},
+		{"zero capacity", 41, 0},
+		{"count exceeds capacity", 49, 11},
+	} {
+		corrupted := append([]byte(nil), data...)
+		binary.BigEndian.PutUint64(corrupted[c.offset:], c.value)
+		var f Filter
+		if err := f.UnmarshalBinary(corrupted); err != ErrorInvalidData {
+			t.Fatalf("%s: want ErrorInvalidData, got %v", c.name, err)
+		}
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	f := New(100, 0.01)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := fmt.Sprint(i, "-", j)
+				f.AddString(key)
+				if !f.TestString(key) {
+					t.Error("false negative", key)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if f.Count() != 8000 {
+		t.Fatal(f.Count())
+	}
+}
@@ -0,0 +1,406 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	adminDefaultLimit = 100
+	adminMaxLimit     = 1000
+	adminHotKeys      = 10 // 缓存信息中展示的热点key数量
+)
+
+// ErrorCacheRegistered 注册的缓存名已存在
+var ErrorCacheRegistered = errors.New("cache already registered")
+
+// Inspectable 可以注册到 Registry 的缓存, 由 LRUCache, LFUCache, LoadingCache 实现
+type Inspectable interface {
+	Size() int
+	Capacity() int
+	Stats() Stats
+	Resize(capacity int)
+
+	// inspect 获取按淘汰顺序倒序排列的第offset个开始的最多limit个key, 以及key的总数
+	inspect(offset, limit int) ([]KeyInfo, int)
+	// invalidate 按编码后的key删除元素, 返回key是否存在
+	invalidate(ctx context.Context, strKey string) (bool, error)
+	invalidateAll(ctx context.Context) error
+}
+
+// KeyInfo 管理接口中展示的key信息
+type KeyInfo struct {
+	Key       string `json:"key"`                 // 编码后的key
+	TTLMillis *int64 `json:"ttlMillis,omitempty"` // 剩余的存活时间, 只有 LoadingCache 有
+	Frequency int    `json:"frequency,omitempty"` // 访问频率, 只有 LFUCache 有
+}
+
+// CacheInfo 管理接口中展示的缓存信息
+type CacheInfo struct {
+	Name     string   `json:"name"`
+	Size     int      `json:"size"`
+	Capacity int      `json:"capacity"`
+	Stats    Stats    `json:"stats"`
+	HitRate  float64  `json:"hitRate"`
+	HotKeys  []HotKey `json:"hotKeys,omitempty"` // 开启 WithHotKeys 时访问最多的key
+}
+
+// hotKeyReporter 开启了热点key统计的缓存, 如 LoadingCache
+type hotKeyReporter interface {
+	HotKeys(n int) []HotKey
+}
+
+// Registry 具名缓存的注册表, 同时也是管理缓存的 http.Handler
+//
+//	GET  /caches                          列出全部缓存的大小, 容量和统计数据
+//	GET  /caches/{name}                   查看单个缓存
+//	GET  /caches/{name}/keys?offset=&limit= 分页查看key, 以及剩余存活时间
+//	GET  /caches/{name}/hot-keys?n=       窗口内访问最多的n个key, 需要开启 WithHotKeys
+//	POST /caches/{name}/invalidate        删除一个key, 请求体 {"key": "..."}
+//	POST /caches/{name}/invalidate-all    清空缓存
+//	POST /caches/{name}/resize            重设容量, 请求体 {"capacity": 100}
+//
+// 挂载到其他路径下时可以配合 http.StripPrefix 使用. key均为经过编码后的字符串
+type Registry struct {
+	mutex  sync.RWMutex
+	caches map[string]Inspectable
+}
+
+// NewRegistry 新建注册表
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]Inspectable)}
+}
+
+// Register 注册缓存, 名字重复时返回 ErrorCacheRegistered
+func (r *Registry) Register(name string, c Inspectable) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.caches[name]; ok {
+		return ErrorCacheRegistered
+	}
+	r.caches[name] = c
+	return nil
+}
+
+// Unregister 取消注册
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.caches, name)
+}
+
+// Get 获取注册的缓存
+func (r *Registry) Get(name string) (Inspectable, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	c, ok := r.caches[name]
+	return c, ok
+}
+
+// Names 获取全部缓存名, 按字典序排序
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	r.mutex.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var segments []string
+	for _, segment := range strings.Split(strings.Trim(req.URL.EscapedPath(), "/"), "/") {
+		segment, err := url.PathUnescape(segment)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid path")
+			return
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 || segments[0] != "caches" {
+		writeAdminError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if len(segments) == 1 {
+		if !allowMethod(w, req, http.MethodGet) {
+			return
+		}
+		infos := make([]CacheInfo, 0)
+		for _, name := range r.Names() {
+			if c, ok := r.Get(name); ok {
+				infos = append(infos, cacheInfo(name, c))
+			}
+		}
+		writeAdminJSON(w, http.StatusOK, infos)
+		return
+	}
+	name := segments[1]
+	c, ok := r.Get(name)
+	if !ok || len(segments) > 3 {
+		writeAdminError(w, http.StatusNotFound, "no such cache: "+name)
+		return
+	}
+	action := ""
+	if len(segments) == 3 {
+		action = segments[2]
+	}
+	switch action {
+	case "":
+		if allowMethod(w, req, http.MethodGet) {
+			writeAdminJSON(w, http.StatusOK, cacheInfo(name, c))
+		}
+	case "keys":
+		if allowMethod(w, req, http.MethodGet) {
+			serveKeys(w, req, c)
+		}
+	case "hot-keys":
+		if allowMethod(w, req, http.MethodGet) {
+			serveHotKeys(w, req, c)
+		}
+	case "invalidate":
+		if allowMethod(w, req, http.MethodPost) {
+			serveInvalidate(w, req, c)
+		}
+	case "invalidate-all":
+		if !allowMethod(w, req, http.MethodPost) {
+			return
+		}
+		if err := c.invalidateAll(req.Context()); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, cacheInfo(name, c))
+	case "resize":
+		if allowMethod(w, req, http.MethodPost) {
+			serveResize(w, req, name, c)
+		}
+	default:
+		writeAdminError(w, http.StatusNotFound, "unknown action: "+action)
+	}
+}
+
+func serveKeys(w http.ResponseWriter, req *http.Request, c Inspectable) {
+	offset, err := queryInt(req, "offset", 0)
+	if err != nil || offset < 0 {
+		writeAdminError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+	limit, err := queryInt(req, "limit", adminDefaultLimit)
+	if err != nil || limit <= 0 {
+		writeAdminError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	if limit > adminMaxLimit {
+		limit = adminMaxLimit
+	}
+	keys, total := c.inspect(offset, limit)
+	if keys == nil {
+		keys = make([]KeyInfo, 0)
+	}
+	writeAdminJSON(w, http.StatusOK, struct {
+		Offset int       `json:"offset"`
+		Limit  int       `json:"limit"`
+		Total  int       `json:"total"`
+		Keys   []KeyInfo `json:"keys"`
+	}{offset, limit, total, keys})
+}
+
+func serveHotKeys(w http.ResponseWriter, req *http.Request, c Inspectable) {
+	n, err := queryInt(req, "n", adminHotKeys)
+	if err != nil || n <= 0 {
+		writeAdminError(w, http.StatusBadRequest, "invalid n")
+		return
+	}
+	reporter, ok := c.(hotKeyReporter)
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, "hot keys are not supported")
+		return
+	}
+	keys := reporter.HotKeys(n)
+	if keys == nil {
+		keys = make([]HotKey, 0)
+	}
+	writeAdminJSON(w, http.StatusOK, keys)
+}
+
+func serveInvalidate(w http.ResponseWriter, req *http.Request, c Inspectable) {
+	var body struct {
+		Key *string `json:"key"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Key == nil {
+		writeAdminError(w, http.StatusBadRequest, "request body must be {\"key\": \"...\"}")
+		return
+	}
+	found, err := c.invalidate(req.Context(), *body.Key)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, struct {
+		Key   string `json:"key"`
+		Found bool   `json:"found"`
+	}{*body.Key, found})
+}
+
+func serveResize(w http.ResponseWriter, req *http.Request, name string, c Inspectable) {
+	var body struct {
+		Capacity *int `json:"capacity"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Capacity == nil || *body.Capacity < 0 {
+		writeAdminError(w, http.StatusBadRequest, "request body must be {\"capacity\": n}, n >= 0")
+		return
+	}
+	c.Resize(*body.Capacity)
+	writeAdminJSON(w, http.StatusOK, cacheInfo(name, c))
+}
+
+func cacheInfo(name string, c Inspectable) CacheInfo {
+	stats := c.Stats()
+	info := CacheInfo{
+		Name:     name,
+		Size:     c.Size(),
+		Capacity: c.Capacity(),
+		Stats:    stats,
+		HitRate:  stats.HitRate(),
+	}
+	if reporter, ok := c.(hotKeyReporter); ok {
+		info.HotKeys = reporter.HotKeys(adminHotKeys)
+	}
+	return info
+}
+
+func allowMethod(w http.ResponseWriter, req *http.Request, method string) bool {
+	if req.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func queryInt(req *http.Request, name string, def int) (int, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, msg string) {
+	writeAdminJSON(w, status, struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+// page 截取第offset个开始的最多limit个元素
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+// inspect 按最近使用到最久未使用的顺序分页
+func (lru *LRUCache[K, V]) inspect(offset, limit int) ([]KeyInfo, int) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	keys := make([]KeyInfo, 0, limit)
+	i := 0
+	for elem := lru.list.Front(); elem != nil && len(keys) < limit; elem = elem.Next() {
+		if i >= offset {
+			keys = append(keys, KeyInfo{Key: lru.stringKey(elem.Value.(*Entry[K, V]).key)})
+		}
+		i++
+	}
+	return keys, lru.list.Len()
+}
+
+func (lru *LRUCache[K, V]) invalidate(_ context.Context, strKey string) (bool, error) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	_, ok := lru.cache[strKey]
+	lru.remove(strKey, RemovalExplicit)
+	return ok, nil
+}
+
+func (lru *LRUCache[K, V]) invalidateAll(_ context.Context) error {
+	lru.Clear()
+	return nil
+}
+
+// inspect 按访问频率从高到低分页
+func (lfu *LFUCache[V]) inspect(offset, limit int) ([]KeyInfo, int) {
+	lfu.mutex.Lock()
+	keys := make([]KeyInfo, 0, len(lfu.pq))
+	for _, item := range lfu.pq {
+		keys = append(keys, KeyInfo{Key: lfu.stringKey(item.key), Frequency: item.frequency})
+	}
+	lfu.mutex.Unlock()
+
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Frequency > keys[j].Frequency })
+	return page(keys, offset, limit), len(keys)
+}
+
+func (lfu *LFUCache[V]) invalidate(_ context.Context, strKey string) (bool, error) {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	_, ok := lfu.cache[strKey]
+	lfu.remove(strKey, RemovalExplicit)
+	return ok, nil
+}
+
+func (lfu *LFUCache[V]) invalidateAll(_ context.Context) error {
+	lfu.Clear()
+	return nil
+}
+
+// inspect 按最近使用到最久未使用的顺序分页, 跳过已过期的元素
+func (c *LoadingCache[K, V]) inspect(offset, limit int) ([]KeyInfo, int) {
+	it := c.lruCache.Iterator()
+	now := c.now()
+	keys := make([]KeyInfo, 0, it.Len())
+	for it.Next() {
+		item := it.Value()
+		if item == nil || !now.Before(item.expire) {
+			continue
+		}
+		ttl := item.expire.Sub(now).Milliseconds()
+		keys = append(keys, KeyInfo{Key: c.stringKey(it.Key()), TTLMillis: &ttl})
+	}
+	return page(keys, offset, limit), len(keys)
+}
+
+// invalidate 删除元素, 接入了失效消息总线时会通知其他副本
+func (c *LoadingCache[K, V]) invalidate(ctx context.Context, strKey string) (bool, error) {
+	c.mutex.Lock()
+	n := c.lruCache.removeStringKeys(strKey)
+	c.discard(strKey)
+	c.unlock()
+	return n > 0, c.broadcast(ctx, InvalidationKey, strKey)
+}
+
+func (c *LoadingCache[K, V]) invalidateAll(ctx context.Context) error {
+	return c.Clear(ctx)
+}
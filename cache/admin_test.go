@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+)
+
+var (
+	_ Inspectable = (*LRUCache[string, int])(nil)
+	_ Inspectable = (*LFUCache[int])(nil)
+	_ Inspectable = (*LoadingCache[string, int])(nil)
+)
+
+func TestStats(t *testing.T) {
+	lru := NewLRUCache(WithCapacity[string, int](2), WithRecordStats[string, int]())
+	lru.Put("a", viktor.Ptr(1))
+	lru.Put("b", viktor.Ptr(2))
+	lru.Get("a")
+	lru.Get("c")
+	lru.Put("c", viktor.Ptr(3))
+	stats := lru.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.HitRate() != 0.5 {
+		t.Fatalf("lru stats = %+v", stats)
+	}
+
+	lfu := NewLFUCacheWithOptions[int](WithCapacity[any, int](1), WithRecordStats[any, int]())
+	lfu.Put("a", viktor.Ptr(1))
+	lfu.Get("a")
+	lfu.Put("b", viktor.Ptr(2))
+	if stats = lfu.Stats(); stats.Hits != 1 || stats.Evictions != 1 || lfu.Size() != 1 {
+		t.Fatalf("lfu stats = %+v", stats)
+	}
+
+	ctx := context.Background()
+	loading := NewLoadingCache(
+		WithCapacity[string, int](10),
+		WithRecordStats[string, int](),
+		WithGetterFunc[string, int](func(key string) (*int, error) {
+			if key == "bad" {
+				return nil, errors.New("bad key")
+			}
+			return viktor.Ptr(len(key)), nil
+		}),
+	)
+	loading.Get(ctx, "abc")
+	loading.Get(ctx, "abc")
+	loading.Get(ctx, "bad")
+	if stats = loading.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Loads != 1 || stats.LoadErrors != 1 {
+		t.Fatalf("loading stats = %+v", stats)
+	}
+
+	disabled := NewLRUCache[string, int]()
+	disabled.Get("a")
+	if stats = disabled.Stats(); stats != (Stats{}) {
+		t.Fatalf("stats recorded without WithRecordStats: %+v", stats)
+	}
+}
+
+func TestResize(t *testing.T) {
+	lru := NewLRUCache(WithCapacity[string, int](3))
+	lfu := NewLFUCache[int](3)
+	for _, key := range []string{"a", "b", "c"} {
+		lru.Put(key, viktor.Ptr(1))
+		lfu.Put(key, viktor.Ptr(1))
+	}
+	lru.Resize(2)
+	lfu.Resize(2)
+	if lru.Size() != 2 || lfu.Size() != 2 {
+		t.Fatalf("after resize lru=%d lfu=%d, want 2", lru.Size(), lfu.Size())
+	}
+	lru.Resize(0)
+	lfu.Resize(0)
+	if lru.Size() != 0 || lfu.Size() != 0 || lru.Capacity() != 0 || lfu.Capacity() != 0 {
+		t.Fatalf("after resize to 0 lru=%d lfu=%d", lru.Size(), lfu.Size())
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry()
+	users := NewLoadingCache(WithCapacity[string, int](10), WithExpireAfterWrite[string, int](time.Minute), WithRecordStats[string, int]())
+	for _, key := range []string{"u1", "u2", "u3"} {
+		users.Put(ctx, key, viktor.Ptr(1))
+	}
+	lfu := NewLFUCache[int](10)
+	lfu.Put("x", viktor.Ptr(1))
+	lfu.Get("x")
+	lfu.Put("y", viktor.Ptr(2))
+	if err := registry.Register("users", users); err != nil {
+		t.Fatal(err)
+	}
+	registry.Register("hot", lfu)
+	if err := registry.Register("users", users); err != ErrorCacheRegistered {
+		t.Fatalf("duplicate register err = %v", err)
+	}
+
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	do := func(method, path, body string, out any) int {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+
+	var infos []CacheInfo
+	if code := do("GET", "/caches", "", &infos); code != 200 || len(infos) != 2 || infos[0].Name != "hot" || infos[1].Size != 3 {
+		t.Fatalf("list = %d %+v", code, infos)
+	}
+
+	var keys struct {
+		Total int
+		Keys  []KeyInfo
+	}
+	if code := do("GET", "/caches/users/keys?offset=1&limit=1", "", &keys); code != 200 || keys.Total != 3 || len(keys.Keys) != 1 {
+		t.Fatalf("keys = %d %+v", code, keys)
+	}
+	if keys.Keys[0].Key != "u2" || keys.Keys[0].TTLMillis == nil || *keys.Keys[0].TTLMillis <= 0 {
+		t.Fatalf("key = %+v", keys.Keys[0])
+	}
+	do("GET", "/caches/hot/keys", "", &keys)
+	if len(keys.Keys) != 2 || keys.Keys[0].Key != "x" || keys.Keys[0].Frequency != 2 {
+		t.Fatalf("lfu keys = %+v", keys.Keys)
+	}
+
+	var invalidated struct{ Found bool }
+	if code := do("POST", "/caches/users/invalidate", `{"key":"u1"}`, &invalidated); code != 200 || !invalidated.Found || users.Contains(ctx, "u1") {
+		t.Fatalf("invalidate = %d %+v", code, invalidated)
+	}
+	var info CacheInfo
+	if code := do("POST", "/caches/users/resize", `{"capacity":1}`, &info); code != 200 || info.Capacity != 1 || info.Size != 1 || info.Stats.Evictions != 1 {
+		t.Fatalf("resize = %d %+v", code, info)
+	}
+	if code := do("POST", "/caches/hot/invalidate-all", "", &info); code != 200 || info.Size != 0 {
+		t.Fatalf("invalidate-all = %d %+v", code, info)
+	}
+
+	if code := do("GET", "/caches/missing", "", nil); code != http.StatusNotFound {
+		t.Fatalf("missing cache status = %d", code)
+	}
+	if code := do("GET", "/caches/users/resize", "", nil); code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET resize status = %d", code)
+	}
+	if code := do("POST", "/caches/users/resize", `{"capacity":-1}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("negative resize status = %d", code)
+	}
+}
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Builder 链式创建缓存, 类型参数只需要在 NewBuilder 时指定一次
+// 设置方法不会panic, 非法的值和不支持的组合在 BuildLRU / BuildLFU / BuildLoading 时以 ErrorInvalidConfig 返回
+//
+//	c, err := cache.NewBuilder[string, User]().
+//		MaximumSize(1000).
+//		ExpireAfterWrite(time.Minute).
+//		Loader(loadUser).
+//		RecordStats().
+//		BuildLoading()
+type Builder[K, V any] struct {
+	conf      *Config[K, V]
+	expireSet bool // 是否设置过过期时间, 默认的过期时间对 LRUCache / LFUCache 没有影响
+	err       error
+}
+
+// NewBuilder 新建 Builder, 未设置的配置使用 NewDefaultConf 的默认值
+func NewBuilder[K, V any]() *Builder[K, V] {
+	return &Builder[K, V]{conf: NewDefaultConf[K, V]()}
+}
+
+func (b *Builder[K, V]) fail(format string, args ...any) *Builder[K, V] {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: "+format, append([]any{ErrorInvalidConfig}, args...)...)
+	}
+	return b
+}
+
+// MaximumSize 最大容量
+func (b *Builder[K, V]) MaximumSize(size int) *Builder[K, V] {
+	if size < 0 {
+		return b.fail("maximum size less than 0")
+	}
+	b.conf.capacity = size
+	return b
+}
+
+// ExpireAfterWrite 写入后的过期时间, 只有 BuildLoading 支持
+func (b *Builder[K, V]) ExpireAfterWrite(d time.Duration) *Builder[K, V] {
+	if d < 0 {
+		return b.fail("expireAfterWrite less than 0")
+	}
+	b.conf.expireAfterWrite = d
+	b.expireSet = true
+	return b
+}
+
+// RefreshAfterWrite 见 WithRefreshAfterWrite, 只有 BuildLoading 支持, 并且需要设置 Loader
+func (b *Builder[K, V]) RefreshAfterWrite(d time.Duration) *Builder[K, V] {
+	if d < 0 {
+		return b.fail("refreshAfterWrite less than 0")
+	}
+	b.conf.refreshAfterWrite = d
+	return b
+}
+
+// KeyEncoder key转化成字符串的方法
+func (b *Builder[K, V]) KeyEncoder(encoder func(key K) string) *Builder[K, V] {
+	b.conf.keyToString = encoder
+	return b
+}
+
+// Loader 缓存不存在时的获取方法, 只有 BuildLoading 支持
+func (b *Builder[K, V]) Loader(loader func(key K) (*V, error)) *Builder[K, V] {
+	b.conf.getterFunc = loader
+	return b
+}
+
+// RemovalListener 见 WithRemovalListener
+func (b *Builder[K, V]) RemovalListener(listener RemovalListener[K, V]) *Builder[K, V] {
+	b.conf.removalListener = listener
+	return b
+}
+
+// RecordStats 见 WithRecordStats
+func (b *Builder[K, V]) RecordStats() *Builder[K, V] {
+	b.conf.recordStats = true
+	return b
+}
+
+// Clock 见 WithClock
+func (b *Builder[K, V]) Clock(clock Clock) *Builder[K, V] {
+	if clock == nil {
+		return b.fail("clock is nil")
+	}
+	b.conf.clock = clock
+	return b
+}
+
+// Spec 应用声明式配置, 见 Spec
+func (b *Builder[K, V]) Spec(spec *Spec) *Builder[K, V] {
+	opts, err := SpecOptions[K, V](spec)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+	if spec.ExpireAfterWrite != nil {
+		b.expireSet = true
+	}
+	return b.Options(opts...)
+}
+
+// Options 应用 Builder 没有对应方法的 Option, 如 WithWriteBehind
+func (b *Builder[K, V]) Options(opts ...Option[K, V]) *Builder[K, V] {
+	for _, opt := range opts {
+		b.conf = opt(b.conf)
+	}
+	return b
+}
+
+// config 校验配置, 返回配置的副本, 之后对 Builder 的修改不会影响已经创建的缓存
+func (b *Builder[K, V]) config(loading bool) (*Config[K, V], error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	conf := *b.conf
+	if !loading {
+		switch {
+		case b.expireSet || conf.expireFunc != nil:
+			return nil, fmt.Errorf("%w: expiration requires a LoadingCache", ErrorInvalidConfig)
+		case conf.getterFunc != nil || conf.loadFunc != nil:
+			return nil, fmt.Errorf("%w: loader requires a LoadingCache", ErrorInvalidConfig)
+		case conf.refreshAfterWrite > 0 || conf.earlyRefreshBeta > 0:
+			return nil, fmt.Errorf("%w: refresh requires a LoadingCache", ErrorInvalidConfig)
+		case conf.writer != nil || conf.deleter != nil || conf.writeBehind != nil:
+			return nil, fmt.Errorf("%w: writer requires a LoadingCache", ErrorInvalidConfig)
+		}
+		return &conf, nil
+	}
+	if (conf.refreshAfterWrite > 0 || conf.earlyRefreshBeta > 0) && conf.getterFunc == nil {
+		return nil, fmt.Errorf("%w: refresh requires a loader", ErrorInvalidConfig)
+	}
+	if conf.refreshAfterWrite > 0 && conf.expireFunc == nil && conf.refreshAfterWrite >= conf.expireAfterWrite {
+		return nil, fmt.Errorf("%w: refreshAfterWrite must be less than expireAfterWrite", ErrorInvalidConfig)
+	}
+	if conf.writer != nil && conf.writeBehind != nil {
+		return nil, fmt.Errorf("%w: write through and write behind can not be used together", ErrorInvalidConfig)
+	}
+	return &conf, nil
+}
+
+// BuildLRU 新建 LRUCache, 设置了过期时间, Loader 等只有 LoadingCache 支持的配置时返回错误
+func (b *Builder[K, V]) BuildLRU() (*LRUCache[K, V], error) {
+	conf, err := b.config(false)
+	if err != nil {
+		return nil, err
+	}
+	return NewLRUCache(WithConfig(conf)), nil
+}
+
+// BuildLFU 新建 LFUCache, 限制同 BuildLRU
+// LFUCache 的key类型为any, KeyEncoder 和 RemovalListener 只会收到类型为K的key
+func (b *Builder[K, V]) BuildLFU() (*LFUCache[V], error) {
+	conf, err := b.config(false)
+	if err != nil {
+		return nil, err
+	}
+	if conf.snapshotCodec != nil {
+		return nil, fmt.Errorf("%w: snapshot codec is not supported by LFUCache builder", ErrorInvalidConfig)
+	}
+	opts := []Option[any, V]{
+		WithCapacity[any, V](conf.capacity),
+		WithMaxPinnedRatio[any, V](conf.maxPinnedRatio),
+		WithEventBuffer[any, V](conf.eventBuffer),
+		WithClock[any, V](conf.clock),
+	}
+	if encoder := conf.keyToString; encoder != nil {
+		opts = append(opts, WithKeyEncoder[any, V](func(key any) string {
+			return encoder(key.(K))
+		}))
+	}
+	if listener := conf.removalListener; listener != nil {
+		opts = append(opts, WithRemovalListener[any, V](func(key any, value *V, cause RemovalCause) {
+			if k, ok := key.(K); ok {
+				listener(k, value, cause)
+			}
+		}))
+	}
+	if conf.recordStats {
+		opts = append(opts, WithRecordStats[any, V]())
+	}
+	return NewLFUCacheWithOptions(opts...), nil
+}
+
+// BuildLoading 新建 LoadingCache, 设置了 RefreshAfterWrite 但没有 Loader 等不合法的组合时返回错误
+func (b *Builder[K, V]) BuildLoading() (*LoadingCache[K, V], error) {
+	conf, err := b.config(true)
+	if err != nil {
+		return nil, err
+	}
+	return NewLoadingCache(WithConfig(conf)), nil
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	var removed []string
+	c, err := NewBuilder[string, int]().
+		MaximumSize(2).
+		ExpireAfterWrite(time.Minute).
+		RefreshAfterWrite(time.Second).
+		Loader(func(key string) (*int, error) {
+			n := len(key)
+			return &n, nil
+		}).
+		RemovalListener(func(key string, _ *int, _ RemovalCause) {
+			removed = append(removed, key)
+		}).
+		RecordStats().
+		BuildLoading()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if v, err := c.Get(ctx, "abc"); err != nil || *v != 3 {
+		t.Fatal(v, err)
+	}
+	c.Get(ctx, "a")
+	c.Get(ctx, "ab")
+	if c.Capacity() != 2 || c.Stats().Misses != 3 || len(removed) != 1 || removed[0] != "abc" {
+		t.Fatal(c.Stats(), removed)
+	}
+
+	lfu, err := NewBuilder[int, string]().MaximumSize(1).RemovalListener(func(key int, _ *string, _ RemovalCause) {
+		removed = append(removed, "lfu")
+	}).BuildLFU()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lfu.Put(1, new(string))
+	lfu.Put(2, new(string))
+	if lfu.Capacity() != 1 || removed[len(removed)-1] != "lfu" {
+		t.Fatal("lfu listener not called")
+	}
+
+	if lru, err := NewBuilder[string, int]().Spec(&Spec{MaximumSize: new(int)}).BuildLRU(); err != nil || lru.Capacity() != 0 {
+		t.Fatal(err)
+	}
+}
+
+func TestBuilderValidate(t *testing.T) {
+	loader := func(key string) (*int, error) { return nil, nil }
+	for name, build := range map[string]func() error{
+		"negative size": func() error {
+			_, err := NewBuilder[string, int]().MaximumSize(-1).BuildLRU()
+			return err
+		},
+		"lru expire": func() error {
+			_, err := NewBuilder[string, int]().ExpireAfterWrite(time.Second).BuildLRU()
+			return err
+		},
+		"lfu loader": func() error {
+			_, err := NewBuilder[string, int]().Loader(loader).BuildLFU()
+			return err
+		},
+		"refresh without loader": func() error {
+			_, err := NewBuilder[string, int]().RefreshAfterWrite(time.Second).BuildLoading()
+			return err
+		},
+		"refresh after expire": func() error {
+			_, err := NewBuilder[string, int]().Loader(loader).ExpireAfterWrite(time.Second).RefreshAfterWrite(time.Minute).BuildLoading()
+			return err
+		},
+	} {
+		if err := build(); !errors.Is(err, ErrorInvalidConfig) {
+			t.Errorf("%s: want ErrorInvalidConfig, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// InvalidationKind 失效消息的类型
+type InvalidationKind uint8
+
+const (
+	InvalidationKey    InvalidationKind = iota + 1 // 按key删除
+	InvalidationTag                                // 按标签删除, 见 Tags
+	InvalidationPrefix                             // 按key前缀删除
+	InvalidationAll                                // 清空缓存
+)
+
+// invalidationSeenSize 每个接入总线的缓存记录的最近消息数量, 用于过滤重复投递的消息
+const invalidationSeenSize = 4096
+
+// Invalidation 失效消息
+type Invalidation struct {
+	Origin string           `json:"origin"` // 发送方ID, 接收方据此过滤自己发出的消息
+	Seq    uint64           `json:"seq"`    // 发送方内递增的序号, 与Origin一起用于去重
+	Cache  string           `json:"cache"`  // 缓存名, 只有同名的缓存会处理该消息
+	Kind   InvalidationKind `json:"kind"`
+	Keys   []string         `json:"keys,omitempty"` // 编码后的key, 或者标签/前缀, 取决于Kind
+}
+
+// InvalidationBus 失效消息总线, 用于在多个进程的缓存副本之间同步删除
+// Publish 发出的消息会投递给所有订阅方, 包括发送方自己, 由订阅方根据 Origin 过滤
+type InvalidationBus interface {
+	Publish(ctx context.Context, msg Invalidation) error
+	Subscribe(handler func(msg Invalidation)) (unsubscribe func(), err error)
+}
+
+// MemoryBus 进程内的失效消息总线, 同步投递, 主要用于测试
+type MemoryBus struct {
+	mutex    sync.Mutex
+	handlers map[uint64]func(msg Invalidation)
+	nextID   uint64
+}
+
+// NewMemoryBus 新建进程内的失效消息总线
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		handlers: make(map[uint64]func(msg Invalidation)),
+	}
+}
+
+// Publish 将消息同步投递给所有订阅方
+func (b *MemoryBus) Publish(_ context.Context, msg Invalidation) error {
+	for _, handler := range b.snapshotHandlers() {
+		handler(msg)
+	}
+	return nil
+}
+
+// Subscribe 订阅消息
+func (b *MemoryBus) Subscribe(handler func(msg Invalidation)) (func(), error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.handlers, id)
+	}, nil
+}
+
+func (b *MemoryBus) snapshotHandlers() []func(msg Invalidation) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	handlers := make([]func(msg Invalidation), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
+
+// busAttachment 一个缓存与总线的连接, 负责生成消息以及过滤自己发出的和重复的消息
+type busAttachment struct {
+	bus         InvalidationBus
+	name        string
+	origin      string
+	seq         uint64
+	seen        *LRUCache[string, struct{}]
+	unsubscribe func()
+}
+
+func newBusAttachment(bus InvalidationBus, name string) *busAttachment {
+	return &busAttachment{
+		bus:    bus,
+		name:   name,
+		origin: newOriginID(),
+		seen:   NewLRUCache[string, struct{}](WithCapacity[string, struct{}](invalidationSeenSize)),
+	}
+}
+
+// subscribe 订阅总线, 只有其他副本发出的同名缓存的消息, 并且第一次收到时才会调用apply
+func (a *busAttachment) subscribe(apply func(msg Invalidation)) error {
+	unsubscribe, err := a.bus.Subscribe(func(msg Invalidation) {
+		if msg.Origin == a.origin || msg.Cache != a.name {
+			return
+		}
+		if _, dup := a.seen.PutIfAbsent(fmt.Sprintf("%s/%d", msg.Origin, msg.Seq), &struct{}{}); dup {
+			return
+		}
+		apply(msg)
+	})
+	if err != nil {
+		return err
+	}
+	a.unsubscribe = unsubscribe
+	return nil
+}
+
+func (a *busAttachment) publish(ctx context.Context, kind InvalidationKind, keys ...string) error {
+	return a.bus.Publish(ctx, Invalidation{
+		Origin: a.origin,
+		Seq:    atomic.AddUint64(&a.seq, 1),
+		Cache:  a.name,
+		Kind:   kind,
+		Keys:   keys,
+	})
+}
+
+func newOriginID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		panic("generate bus origin id failed: " + err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// AttachBus 将缓存接入失效消息总线, 同一个缓存的各个副本应使用相同的name
+// 接入后本地的写入和删除会广播给其他副本, 收到其他副本的消息时删除本地对应的元素; 通过加载写入的数据不会广播
+// 返回的detach用于断开总线, 一个缓存同一时刻只能接入一个总线
+func (c *LoadingCache[K, V]) AttachBus(bus InvalidationBus, name string) (detach func(), err error) {
+	c.mutex.Lock()
+	defer c.unlock()
+	if current, _ := c.bus.Load().(*busAttachment); current != nil {
+		return nil, ErrorBusAttached
+	}
+	attachment := newBusAttachment(bus, name)
+	if err = attachment.subscribe(c.applyInvalidation); err != nil {
+		return nil, err
+	}
+	c.bus.Store(attachment)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			attachment.unsubscribe()
+			c.mutex.Lock()
+			defer c.unlock()
+			if current, _ := c.bus.Load().(*busAttachment); current == attachment {
+				c.bus.Store((*busAttachment)(nil))
+			}
+		})
+	}, nil
+}
+
+// broadcast 向总线广播失效消息, 未接入总线时什么也不做. 调用方不能持有锁
+func (c *LoadingCache[K, V]) broadcast(ctx context.Context, kind InvalidationKind, keys ...string) error {
+	attachment, _ := c.bus.Load().(*busAttachment)
+	if attachment == nil {
+		return nil
+	}
+	return attachment.publish(ctx, kind, keys...)
+}
+
+// applyInvalidation 处理其他副本发来的失效消息
+func (c *LoadingCache[K, V]) applyInvalidation(msg Invalidation) {
+	c.mutex.Lock()
+	defer c.unlock()
+	switch msg.Kind {
+	case InvalidationKey:
+		c.lruCache.removeStringKeys(msg.Keys...)
+		for _, strKey := range msg.Keys {
+			c.discard(strKey)
+		}
+	case InvalidationTag:
+		for _, tag := range msg.Keys {
+			for _, strKey := range c.lruCache.invalidateTag(tag) {
+				c.discard(strKey)
+			}
+		}
+	case InvalidationPrefix:
+		for _, prefix := range msg.Keys {
+			c.lruCache.InvalidatePrefix(prefix)
+			c.discardIf(func(strKey string) bool { return strings.HasPrefix(strKey, prefix) })
+		}
+	case InvalidationAll:
+		c.lruCache.Clear()
+		c.discardIf(func(string) bool { return true })
+	}
+}
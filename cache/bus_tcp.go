@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	tcpBusWriteTimeout  = time.Second * 5
+	tcpBusMaxMessageLen = 1 << 20
+)
+
+// TCPBus 基于TCP扇出的失效消息总线
+// 每个进程监听一个地址, Publish 时投递给本进程的订阅方, 并发送给所有peer; peer收到后投递给它自己的订阅方, 不会再转发
+// 消息以每行一个JSON的格式传输, 连接断开后会在下次发送时重连. peer列表中可以包含自己的地址, 发送时会跳过
+type TCPBus struct {
+	listener net.Listener
+	local    *MemoryBus
+	mutex    sync.Mutex
+	peers    map[string]*tcpPeer
+	inbound  map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type tcpPeer struct {
+	addr  string
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// NewTCPBus 在listenAddr上监听并新建总线, listenAddr 可以使用 "127.0.0.1:0" 随机端口, 通过 Addr 获取实际地址
+func NewTCPBus(listenAddr string, peers ...string) (*TCPBus, error) {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	b := &TCPBus{
+		listener: listener,
+		local:    NewMemoryBus(),
+		peers:    make(map[string]*tcpPeer),
+		inbound:  make(map[net.Conn]struct{}),
+	}
+	b.SetPeers(peers...)
+	b.wg.Add(1)
+	go b.acceptLoop()
+	return b, nil
+}
+
+// Addr 实际监听的地址
+func (b *TCPBus) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// SetPeers 替换peer列表, 不在新列表中的连接会被关闭
+func (b *TCPBus) SetPeers(peers ...string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	next := make(map[string]*tcpPeer, len(peers))
+	for _, addr := range peers {
+		if addr == b.Addr() {
+			continue
+		}
+		if peer, ok := b.peers[addr]; ok {
+			next[addr] = peer
+			continue
+		}
+		next[addr] = &tcpPeer{addr: addr}
+	}
+	for addr, peer := range b.peers {
+		if _, ok := next[addr]; !ok {
+			peer.close()
+		}
+	}
+	b.peers = next
+}
+
+// Publish 投递给本进程的订阅方并发送给所有peer
+// 发送给某个peer失败不影响其他peer, 返回遇到的第一个错误
+func (b *TCPBus) Publish(ctx context.Context, msg Invalidation) error {
+	if err := b.local.Publish(ctx, msg); err != nil {
+		return err
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	b.mutex.Lock()
+	peers := make([]*tcpPeer, 0, len(b.peers))
+	for _, peer := range b.peers {
+		peers = append(peers, peer)
+	}
+	b.mutex.Unlock()
+
+	var firstErr error
+	for _, peer := range peers {
+		if err = peer.send(ctx, data); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("publish to %s: %w", peer.addr, err)
+		}
+	}
+	return firstErr
+}
+
+// Subscribe 订阅消息, 包括本进程发出的和从peer收到的
+func (b *TCPBus) Subscribe(handler func(msg Invalidation)) (func(), error) {
+	return b.local.Subscribe(handler)
+}
+
+// Close 停止监听并关闭所有连接
+func (b *TCPBus) Close() error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	err := b.listener.Close()
+	for _, peer := range b.peers {
+		peer.close()
+	}
+	for conn := range b.inbound {
+		conn.Close()
+	}
+	b.mutex.Unlock()
+	b.wg.Wait()
+	return err
+}
+
+func (b *TCPBus) acceptLoop() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mutex.Lock()
+		if b.closed {
+			b.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		b.inbound[conn] = struct{}{}
+		b.wg.Add(1)
+		b.mutex.Unlock()
+		go b.readLoop(conn)
+	}
+}
+
+func (b *TCPBus) readLoop(conn net.Conn) {
+	defer b.wg.Done()
+	defer func() {
+		b.mutex.Lock()
+		delete(b.inbound, conn)
+		b.mutex.Unlock()
+		conn.Close()
+	}()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), tcpBusMaxMessageLen)
+	for scanner.Scan() {
+		var msg Invalidation
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		b.local.Publish(context.Background(), msg)
+	}
+}
+
+// send 发送一条消息, 已有连接写入失败时重连一次
+func (p *tcpPeer) send(ctx context.Context, data []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if p.conn == nil {
+			var dialer net.Dialer
+			if p.conn, err = dialer.DialContext(ctx, "tcp", p.addr); err != nil {
+				return err
+			}
+		}
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			deadline = time.Now().Add(tcpBusWriteTimeout)
+		}
+		p.conn.SetWriteDeadline(deadline)
+		if _, err = p.conn.Write(data); err == nil {
+			return nil
+		}
+		p.conn.Close()
+		p.conn = nil
+	}
+	return err
+}
+
+func (p *tcpPeer) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+)
+
+func TestLoadingCacheMemoryBus(t *testing.T) {
+	ctx := context.Background()
+	bus := NewMemoryBus()
+	a := NewLoadingCache[string, int]()
+	b := NewLoadingCache[string, int]()
+	other := NewLoadingCache[string, int]()
+	if _, err := a.AttachBus(bus, "users"); err != nil {
+		t.Fatal(err)
+	}
+	detachB, err := b.AttachBus(bus, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = other.AttachBus(bus, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.AttachBus(bus, "users"); err != ErrorBusAttached {
+		t.Fatalf("err=%v, want ErrorBusAttached", err)
+	}
+
+	b.Put(ctx, "1", viktor.Ptr(1))
+	other.Put(ctx, "1", viktor.Ptr(1))
+	a.Put(ctx, "1", viktor.Ptr(2))
+	if !a.Contains(ctx, "1") {
+		t.Fatal("self-originated invalidation should be ignored")
+	}
+	if b.Contains(ctx, "1") {
+		t.Fatal("write on a should invalidate b")
+	}
+	if !other.Contains(ctx, "1") {
+		t.Fatal("invalidation should only affect caches with the same name")
+	}
+
+	b.PutWithOptions(ctx, "2", viktor.Ptr(2), Tags("t"))
+	a.InvalidateTag(ctx, "t")
+	if b.Contains(ctx, "2") {
+		t.Fatal("tag invalidation should be broadcast")
+	}
+
+	detachB()
+	b.Put(ctx, "3", viktor.Ptr(3))
+	a.Remove(ctx, "3")
+	if !b.Contains(ctx, "3") {
+		t.Fatal("detached cache should not receive invalidations")
+	}
+}
+
+func TestBusAttachmentDeduplicate(t *testing.T) {
+	bus := NewMemoryBus()
+	attachment := newBusAttachment(bus, "c")
+	applied := 0
+	if err := attachment.subscribe(func(msg Invalidation) { applied++ }); err != nil {
+		t.Fatal(err)
+	}
+	msg := Invalidation{Origin: "remote", Seq: 1, Cache: "c", Kind: InvalidationAll}
+	bus.Publish(context.Background(), msg)
+	bus.Publish(context.Background(), msg)
+	attachment.publish(context.Background(), InvalidationAll)
+	if applied != 1 {
+		t.Fatalf("applied=%d, want 1", applied)
+	}
+}
+
+func TestLoadingCacheTCPBus(t *testing.T) {
+	ctx := context.Background()
+	busA, err := NewTCPBus("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busA.Close()
+	busB, err := NewTCPBus("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busB.Close()
+	peers := []string{busA.Addr(), busB.Addr()}
+	busA.SetPeers(peers...)
+	busB.SetPeers(peers...)
+
+	a := NewLoadingCache[string, int]()
+	b := NewLoadingCache[string, int]()
+	b.Put(ctx, "1", viktor.Ptr(1))
+	if _, err = a.AttachBus(busA, "users"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.AttachBus(busB, "users"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = a.Put(ctx, "1", viktor.Ptr(2)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	for b.Contains(ctx, "1") {
+		if time.Now().After(deadline) {
+			t.Fatal("invalidation not received over tcp")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if !a.Contains(ctx, "1") {
+		t.Fatal("self-originated invalidation should be ignored")
+	}
+}
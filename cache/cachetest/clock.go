@@ -0,0 +1,114 @@
+// Package cachetest 提供测试缓存用的工具
+package cachetest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myron934/go-viktor/cache"
+)
+
+// FakeClock 手动推进的时钟, 实现了 cache.Clock
+// 定时器只会在 Advance / Set 时, 在调用方的goroutine中按触发时间顺序同步执行, 因此测试是确定的
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	seq    uint64
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	seq   uint64 // 触发时间相同时按创建顺序执行
+	f     func()
+}
+
+var _ cache.Clock = (*FakeClock)(nil)
+
+// NewFakeClock 新建时钟, 初始时间为start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now 当前时间
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// AfterFunc 创建定时器, 在时钟推进d之后执行f, d<=0时在下一次 Advance 时执行
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) cache.Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.seq++
+	t := &fakeTimer{clock: c, when: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance 将时钟推进d, 并依次执行期间到期的定时器
+// 定时器回调中新建的定时器如果也在推进的范围内, 同样会被执行
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set 将时钟设置为t, 并依次执行期间到期的定时器, t早于当前时间时只修改时间
+func (c *FakeClock) Set(t time.Time) {
+	for {
+		c.mutex.Lock()
+		timer := c.nextTimer(t)
+		if timer == nil {
+			c.now = t
+			c.mutex.Unlock()
+			return
+		}
+		if timer.when.After(c.now) {
+			c.now = timer.when
+		}
+		c.mutex.Unlock()
+		timer.f()
+	}
+}
+
+// Timers 未触发的定时器数量
+func (c *FakeClock) Timers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+// nextTimer 取出不晚于deadline的最早的定时器, 调用方需持有锁
+func (c *FakeClock) nextTimer(deadline time.Time) *fakeTimer {
+	if len(c.timers) == 0 {
+		return nil
+	}
+	sort.Slice(c.timers, func(i, j int) bool {
+		if c.timers[i].when.Equal(c.timers[j].when) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	timer := c.timers[0]
+	if timer.when.After(deadline) {
+		return nil
+	}
+	c.timers = c.timers[1:]
+	return timer
+}
+
+// Stop 取消定时器
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
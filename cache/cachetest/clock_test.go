@@ -0,0 +1,113 @@
+package cachetest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+	"github.com/myron934/go-viktor/cache"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	var fired []int
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, 1)
+		// 回调中新建的定时器在推进范围内, 同样会被执行
+		clock.AfterFunc(time.Second, func() { fired = append(fired, 3) })
+	})
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, -1) })
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatal("Stop should succeed exactly once")
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	if len(fired) != 1 || fired[0] != 1 {
+		t.Fatalf("fired = %v, want [1]", fired)
+	}
+	clock.Advance(time.Second)
+	if len(fired) != 3 || fired[1] != 2 || fired[2] != 3 {
+		t.Fatalf("fired = %v, want [1 2 3]", fired)
+	}
+	if got := clock.Now(); !got.Equal(start.Add(2500 * time.Millisecond)) {
+		t.Fatalf("now = %v", got)
+	}
+}
+
+func TestLoadingCacheExpire(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	c := cache.NewLoadingCache(
+		cache.WithClock[string, int](clock),
+		cache.WithExpireAfterWrite[string, int](time.Minute),
+		cache.WithClearInterval[string, int](30*time.Second),
+	)
+	defer c.Close(ctx)
+	c.Put(ctx, "a", viktor.Ptr(1))
+	clock.Advance(59 * time.Second)
+	if !c.Contains(ctx, "a") {
+		t.Fatal("a expired too early")
+	}
+	// 定时清理在推进时钟时同步执行, 清空后不再等待下一次清理
+	clock.Advance(time.Second)
+	if c.Contains(ctx, "a") || c.Size() != 0 || clock.Timers() != 0 {
+		t.Fatalf("a should be swept, size = %d, timers = %d", c.Size(), clock.Timers())
+	}
+
+	c.Put(ctx, "b", viktor.Ptr(2))
+	clock.Advance(30 * time.Second)
+	if c.Size() != 1 || clock.Timers() != 1 {
+		t.Fatalf("b swept too early, size = %d, timers = %d", c.Size(), clock.Timers())
+	}
+	clock.Advance(30 * time.Second)
+	if c.Size() != 0 || clock.Timers() != 0 {
+		t.Fatalf("after sweep size = %d, timers = %d", c.Size(), clock.Timers())
+	}
+
+	c.Put(ctx, "c", viktor.Ptr(3))
+	c.Close(ctx)
+	if clock.Timers() != 0 {
+		t.Fatal("Close should stop the sweeper")
+	}
+}
+
+func TestLoadingCacheRefreshAfterWrite(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	var version int32
+	c := cache.NewLoadingCache(
+		cache.WithClock[string, int32](clock),
+		cache.WithExpireAfterWrite[string, int32](time.Minute),
+		cache.WithRefreshAfterWrite[string, int32](10*time.Second),
+		cache.WithGetterFunc[string, int32](func(string) (*int32, error) {
+			return viktor.Ptr(atomic.AddInt32(&version, 1)), nil
+		}),
+	)
+	defer c.Close(ctx)
+	if v := c.MustGet(ctx, "k"); *v != 1 {
+		t.Fatalf("first load = %d", *v)
+	}
+	clock.Advance(5 * time.Second)
+	if v := c.MustGet(ctx, "k"); *v != 1 || atomic.LoadInt32(&version) != 1 {
+		t.Fatal("refreshed too early")
+	}
+	clock.Advance(5 * time.Second)
+	// 到达刷新时间后先返回旧值, 后台重新加载
+	if v := c.MustGet(ctx, "k"); *v != 1 {
+		t.Fatalf("stale read = %d, want 1", *v)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if v, _ := c.Peek(ctx, "k"); v != nil && *v == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,269 @@
+package cachetest
+
+import (
+	"container/list"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Cache 接入一致性测试的缓存, 通过适配器包装具体的缓存类型, key和value固定为string和int
+type Cache interface {
+	Get(key string) (int, bool)
+	Put(key string, value int)
+	Remove(key string)
+	// Len 当前元素数量, 可以包含已过期但还没有清理的元素
+	Len() int
+}
+
+// Model 精确的参考淘汰策略, 预测每次 Get 是否命中; 容量和操作序列相同时, 被测缓存的命中情况必须与之一致
+type Model interface {
+	Get(key string) bool
+	Put(key string)
+	Remove(key string)
+}
+
+// Suite 缓存的一致性测试, 对随机的操作序列与简单的参考模型比较:
+//   - 任何时候元素数量都不超过容量
+//   - 命中时返回的一定是最后一次写入的值, 删除或过期的key一定不命中
+//   - 刚写入的key一定命中, 元素数量不超过容量时不会淘汰
+//   - 设置了 Model 时命中情况与 Model 完全一致
+//   - 设置了 TTL 时用 FakeClock 推进时间, 检查过期语义
+//   - 多个goroutine并发操作, 配合 -race 检查数据竞争, 运行时间有上限
+type Suite struct {
+	// New 新建容量为capacity的缓存, 设置了TTL时写入的元素需要在TTL后过期, 并使用clock作为时间源
+	New func(capacity int, clock *FakeClock) Cache
+	// NewModel 新建参考策略, 为nil时只检查与策略无关的性质; 只在TTL为0时使用
+	NewModel func(capacity int) Model
+	// TTL 写入后的过期时间, 0表示被测缓存不会过期
+	TTL time.Duration
+
+	Capacity    int           // 容量, 默认为8
+	Keys        int           // 随机操作的key的数量, 默认为容量的3倍
+	Ops         int           // 每个操作序列的长度, 默认为2000
+	Seeds       int           // 操作序列的数量, 默认为20
+	Parallelism int           // 并发测试的goroutine数量, 默认为8
+	Duration    time.Duration // 并发测试的时长, 默认为100ms
+}
+
+func (s Suite) withDefaults() Suite {
+	if s.Capacity <= 0 {
+		s.Capacity = 8
+	}
+	if s.Keys <= 0 {
+		s.Keys = s.Capacity * 3
+	}
+	if s.Ops <= 0 {
+		s.Ops = 2000
+	}
+	if s.Seeds <= 0 {
+		s.Seeds = 20
+	}
+	if s.Parallelism <= 0 {
+		s.Parallelism = 8
+	}
+	if s.Duration <= 0 {
+		s.Duration = time.Millisecond * 100
+	}
+	return s
+}
+
+// Run 运行全部测试
+func (s Suite) Run(t *testing.T) {
+	s = s.withDefaults()
+	t.Run("Fill", s.testFill)
+	t.Run("Random", s.testRandom)
+	t.Run("Concurrent", s.testConcurrent)
+}
+
+func (s Suite) newCache() (Cache, *FakeClock) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	return s.New(s.Capacity, clock), clock
+}
+
+// testFill 容量以内的写入不会淘汰
+func (s Suite) testFill(t *testing.T) {
+	c, _ := s.newCache()
+	for i := 0; i < s.Capacity; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	if n := c.Len(); n != s.Capacity {
+		t.Fatalf("len = %d after filling to capacity %d", n, s.Capacity)
+	}
+	for i := 0; i < s.Capacity; i++ {
+		if v, ok := c.Get(strconv.Itoa(i)); !ok || v != i {
+			t.Fatalf("key %d evicted or changed before reaching capacity: %v, %v", i, v, ok)
+		}
+	}
+}
+
+type modelEntry struct {
+	value  int
+	expire time.Time
+}
+
+// testRandom 随机操作序列与参考模型比较, 失败时输出seed和步数以便复现
+func (s Suite) testRandom(t *testing.T) {
+	for seed := int64(1); seed <= int64(s.Seeds); seed++ {
+		if err := s.runSequence(seed); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
+
+func (s Suite) runSequence(seed int64) error {
+	r := rand.New(rand.NewSource(seed))
+	c, clock := s.newCache()
+	var model Model
+	if s.NewModel != nil && s.TTL == 0 {
+		model = s.NewModel(s.Capacity)
+	}
+	entries := make(map[string]modelEntry)
+	live := func(key string) (modelEntry, bool) {
+		e, ok := entries[key]
+		if ok && s.TTL > 0 && !clock.Now().Before(e.expire) {
+			return e, false
+		}
+		return e, ok
+	}
+	get := func(step int, key string) error {
+		v, ok := c.Get(key)
+		e, present := live(key)
+		if ok && !present {
+			return fmt.Errorf("step %d: get %s returned %d for an absent or expired key", step, key, v)
+		}
+		if ok && v != e.value {
+			return fmt.Errorf("step %d: get %s returned %d, want %d", step, key, v, e.value)
+		}
+		if model != nil {
+			if want := model.Get(key); want != ok {
+				return fmt.Errorf("step %d: get %s hit=%v, model hit=%v", step, key, ok, want)
+			}
+		}
+		return nil
+	}
+
+	for step := 0; step < s.Ops; step++ {
+		key := strconv.Itoa(r.Intn(s.Keys))
+		switch op := r.Intn(10); {
+		case op < 4:
+			value := r.Int()
+			c.Put(key, value)
+			entries[key] = modelEntry{value: value, expire: clock.Now().Add(s.TTL)}
+			if model != nil {
+				model.Put(key)
+			}
+			// 刚写入的key一定命中
+			if v, ok := c.Get(key); !ok || v != value {
+				return fmt.Errorf("step %d: key %s missing right after put", step, key)
+			}
+			if model != nil {
+				model.Get(key)
+			}
+		case op < 8:
+			if err := get(step, key); err != nil {
+				return err
+			}
+		case op < 9 || s.TTL == 0:
+			c.Remove(key)
+			delete(entries, key)
+			if model != nil {
+				model.Remove(key)
+			}
+			if _, ok := c.Get(key); ok {
+				return fmt.Errorf("step %d: key %s present after remove", step, key)
+			}
+		default:
+			clock.Advance(time.Duration(r.Int63n(int64(s.TTL))))
+		}
+		if n := c.Len(); n > s.Capacity {
+			return fmt.Errorf("step %d: len %d exceeds capacity %d", step, n, s.Capacity)
+		}
+	}
+	return nil
+}
+
+// testConcurrent 并发随机操作, 每个key只会写入由key决定的值, 因此命中时的值可以校验
+func (s Suite) testConcurrent(t *testing.T) {
+	c, _ := s.newCache()
+	deadline := time.Now().Add(s.Duration)
+	var wg sync.WaitGroup
+	errs := make(chan error, s.Parallelism)
+	for i := 0; i < s.Parallelism; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for n := 0; n%64 != 0 || time.Now().Before(deadline); n++ {
+				k := r.Intn(s.Keys)
+				key := strconv.Itoa(k)
+				switch r.Intn(10) {
+				case 0, 1, 2:
+					c.Put(key, k)
+				case 3:
+					c.Remove(key)
+				default:
+					if v, ok := c.Get(key); ok && v != k {
+						errs <- fmt.Errorf("get %s returned %d", key, v)
+						return
+					}
+				}
+				if l := c.Len(); l > s.Capacity {
+					errs <- fmt.Errorf("len %d exceeds capacity %d", l, s.Capacity)
+					return
+				}
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// LRUModel 精确的LRU参考策略
+type LRUModel struct {
+	capacity int
+	list     *list.List
+	items    map[string]*list.Element
+}
+
+// NewLRUModel 新建LRU参考策略
+func NewLRUModel(capacity int) Model {
+	return &LRUModel{capacity: capacity, list: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (m *LRUModel) Get(key string) bool {
+	elem, ok := m.items[key]
+	if ok {
+		m.list.MoveToFront(elem)
+	}
+	return ok
+}
+
+func (m *LRUModel) Put(key string) {
+	if elem, ok := m.items[key]; ok {
+		m.list.MoveToFront(elem)
+		return
+	}
+	if m.capacity == 0 {
+		return
+	}
+	if m.list.Len() >= m.capacity {
+		back := m.list.Back()
+		m.list.Remove(back)
+		delete(m.items, back.Value.(string))
+	}
+	m.items[key] = m.list.PushFront(key)
+}
+
+func (m *LRUModel) Remove(key string) {
+	if elem, ok := m.items[key]; ok {
+		m.list.Remove(elem)
+		delete(m.items, key)
+	}
+}
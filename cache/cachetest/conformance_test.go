@@ -0,0 +1,58 @@
+package cachetest
+
+import (
+	"strings"
+	"testing"
+)
+
+// unboundedCache 从不淘汰的缓存, 用来验证一致性测试能发现问题
+type unboundedCache map[string]int
+
+func (c unboundedCache) Get(key string) (int, bool) { v, ok := c[key]; return v, ok }
+func (c unboundedCache) Put(key string, value int)  { c[key] = value }
+func (c unboundedCache) Remove(key string)          { delete(c, key) }
+func (c unboundedCache) Len() int                   { return len(c) }
+
+func TestSuiteDetectsViolations(t *testing.T) {
+	s := Suite{
+		New: func(int, *FakeClock) Cache { return unboundedCache{} },
+	}.withDefaults()
+	if err := s.runSequence(1); err == nil || !strings.Contains(err.Error(), "exceeds capacity") {
+		t.Fatalf("want capacity violation, got %v", err)
+	}
+
+	// 元素数量看起来没有超过容量, 但模型已经淘汰的key仍然命中
+	s.New = func(capacity int, _ *FakeClock) Cache { return cappedLen{unboundedCache{}, capacity} }
+	s.NewModel = NewLRUModel
+	if err := s.runSequence(1); err == nil || !strings.Contains(err.Error(), "model hit=false") {
+		t.Fatalf("want model mismatch, got %v", err)
+	}
+}
+
+// cappedLen 隐瞒元素数量的缓存
+type cappedLen struct {
+	unboundedCache
+	capacity int
+}
+
+func (c cappedLen) Len() int {
+	if n := c.unboundedCache.Len(); n < c.capacity {
+		return n
+	}
+	return c.capacity
+}
+
+func TestLRUModel(t *testing.T) {
+	m := NewLRUModel(2)
+	m.Put("a")
+	m.Put("b")
+	m.Get("a")
+	m.Put("c")
+	if !m.Get("a") || m.Get("b") || !m.Get("c") {
+		t.Fatal("b should be evicted")
+	}
+	m.Remove("a")
+	if m.Get("a") {
+		t.Fatal("a should be removed")
+	}
+}
@@ -0,0 +1,29 @@
+package cache
+
+import "time"
+
+// Clock 时间源, 用于计算过期, 刷新以及定时清理, 测试中可以替换为 cachetest.FakeClock
+type Clock interface {
+	Now() time.Time
+	// AfterFunc 在d之后调用f, 返回的 Timer 可以用来取消
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer Clock.AfterFunc 创建的定时器
+type Timer interface {
+	// Stop 取消定时器, 定时器已经触发或已经取消时返回false
+	Stop() bool
+}
+
+// SystemClock 基于 time 包的系统时钟, 默认使用
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
@@ -0,0 +1,301 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ClockCache CLOCK(二次机会) 淘汰策略缓存
+// 元素放在环形数组中, Get 只在读锁下原子地设置访问标记, 不修改任何共享结构, 多核下读操作可以并行;
+// 淘汰时指针沿环扫描, 清除遇到的访问标记, 淘汰第一个没有被访问过的元素. 命中率接近 LRUCache
+type ClockCache[K, V any] struct {
+	mutex sync.RWMutex
+	cache map[string]int // 编码后的key到ring中下标的映射
+	ring  []*clockEntry[K, V]
+	hand  int // 下一次淘汰开始扫描的位置
+	conf  *Config[K, V]
+	stats *statsCounter
+
+	pending []removal[K, V] // 等待回调的删除通知, 见 unlock
+	events  *eventHub[K, V]
+}
+
+type clockEntry[K, V any] struct {
+	key        K
+	strKey     string
+	value      *V
+	referenced int32 // 上一次扫描以后是否被访问过
+}
+
+// NewClockCache 新建clock缓存(并发安全), 支持 WithCapacity, WithKeyEncoder, WithRecordStats, WithRemovalListener, WithEventBuffer
+func NewClockCache[K, V any](opts ...Option[K, V]) *ClockCache[K, V] {
+	c := &ClockCache[K, V]{
+		cache: make(map[string]int),
+		conf:  NewDefaultConf[K, V](),
+	}
+	for _, opt := range opts {
+		c.conf = opt(c.conf)
+	}
+	if c.conf.clock == nil {
+		// WithConfig 传入的配置可能没有设置时钟
+		c.conf.clock = SystemClock
+	}
+	c.stats = newStatsCounter(c.conf.recordStats)
+	c.events = &eventHub[K, V]{}
+	return c
+}
+
+// Get 获取数据, 只需要读锁
+func (c *ClockCache[K, V]) Get(key K) (*V, error) {
+	strKey := c.stringKey(key)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	idx, ok := c.cache[strKey]
+	if !ok {
+		c.stats.recordMiss()
+		c.emit(EventMiss, key, nil)
+		return nil, ErrorKeyNotFound
+	}
+	entry := c.ring[idx]
+	// 已经设置过时不再写, 避免热点key所在的缓存行在核之间来回失效
+	if atomic.LoadInt32(&entry.referenced) == 0 {
+		atomic.StoreInt32(&entry.referenced, 1)
+	}
+	c.stats.recordHit()
+	c.emit(EventHit, key, entry.value)
+	return entry.value, nil
+}
+
+// MustGet 同 Get, 如果key不存在返回nil
+func (c *ClockCache[K, V]) MustGet(key K) *V {
+	val, _ := c.Get(key)
+	return val
+}
+
+// Peek 获取数据, 但不设置访问标记
+func (c *ClockCache[K, V]) Peek(key K) (*V, bool) {
+	strKey := c.stringKey(key)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	idx, ok := c.cache[strKey]
+	if !ok {
+		return nil, false
+	}
+	return c.ring[idx].value, true
+}
+
+// Contains 判断key是否存在, 不设置访问标记
+func (c *ClockCache[K, V]) Contains(key K) bool {
+	strKey := c.stringKey(key)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	_, ok := c.cache[strKey]
+	return ok
+}
+
+// Put 设置缓存数据, 容量已满时淘汰一个元素
+func (c *ClockCache[K, V]) Put(key K, value *V) {
+	strKey := c.stringKey(key)
+	c.mutex.Lock()
+	defer c.unlock()
+
+	if idx, ok := c.cache[strKey]; ok {
+		c.emit(EventPut, key, value)
+		entry := c.ring[idx]
+		if entry.value != value {
+			c.notify(entry, RemovalReplaced)
+		}
+		entry.value = value
+		atomic.StoreInt32(&entry.referenced, 1)
+		return
+	}
+	if c.conf.capacity == 0 {
+		return
+	}
+	entry := &clockEntry[K, V]{key: key, strKey: strKey, value: value}
+	if len(c.ring) < c.conf.capacity {
+		c.emit(EventPut, key, value)
+		c.cache[strKey] = len(c.ring)
+		c.ring = append(c.ring, entry)
+		return
+	}
+	idx := c.victim()
+	c.stats.recordEviction()
+	c.notify(c.ring[idx], RemovalEvicted)
+	c.emit(EventPut, key, value)
+	delete(c.cache, c.ring[idx].strKey)
+	c.ring[idx] = entry
+	c.cache[strKey] = idx
+	c.hand = (idx + 1) % len(c.ring)
+}
+
+// victim 从hand开始扫描, 清除访问标记, 返回第一个没有被访问过的元素的下标; 最多扫描两圈
+func (c *ClockCache[K, V]) victim() int {
+	for {
+		entry := c.ring[c.hand]
+		if atomic.LoadInt32(&entry.referenced) == 0 {
+			return c.hand
+		}
+		atomic.StoreInt32(&entry.referenced, 0)
+		c.hand = (c.hand + 1) % len(c.ring)
+	}
+}
+
+// Remove 删除元素
+func (c *ClockCache[K, V]) Remove(key K) {
+	strKey := c.stringKey(key)
+	c.mutex.Lock()
+	defer c.unlock()
+
+	c.remove(strKey, RemovalExplicit)
+}
+
+// remove 把最后一个元素移动到被删除的位置
+func (c *ClockCache[K, V]) remove(strKey string, cause RemovalCause) bool {
+	idx, ok := c.cache[strKey]
+	if !ok {
+		return false
+	}
+	c.notify(c.ring[idx], cause)
+	delete(c.cache, strKey)
+	last := len(c.ring) - 1
+	if idx != last {
+		c.ring[idx] = c.ring[last]
+		c.cache[c.ring[idx].strKey] = idx
+	}
+	c.ring[last] = nil
+	c.ring = c.ring[:last]
+	if c.hand >= len(c.ring) {
+		c.hand = 0
+	}
+	return true
+}
+
+// Clear 清空缓存
+func (c *ClockCache[K, V]) Clear() {
+	c.mutex.Lock()
+	defer c.unlock()
+
+	for _, entry := range c.ring {
+		c.notify(entry, RemovalExplicit)
+	}
+	c.cache = make(map[string]int)
+	c.ring = nil
+	c.hand = 0
+}
+
+// Keys 获取全部key, 顺序不确定
+func (c *ClockCache[K, V]) Keys() []K {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	keys := make([]K, 0, len(c.ring))
+	for _, entry := range c.ring {
+		keys = append(keys, entry.key)
+	}
+	return keys
+}
+
+// Size 获取当前元素数量
+func (c *ClockCache[K, V]) Size() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return len(c.ring)
+}
+
+// Capacity 获取最大容量
+func (c *ClockCache[K, V]) Capacity() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.conf.capacity
+}
+
+// Resize 重设缓存大小
+func (c *ClockCache[K, V]) Resize(capacity int) {
+	if capacity < 0 {
+		panic("capacity less than 0")
+	}
+	c.mutex.Lock()
+	defer c.unlock()
+
+	for len(c.ring) > capacity {
+		c.stats.recordEviction()
+		c.remove(c.ring[c.victim()].strKey, RemovalEvicted)
+	}
+	c.conf.capacity = capacity
+}
+
+// Stats 获取统计数据, 需要通过 WithRecordStats 开启
+func (c *ClockCache[K, V]) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+func (c *ClockCache[K, V]) notify(entry *clockEntry[K, V], cause RemovalCause) {
+	if t, ok := removalEvent(cause); ok {
+		c.emit(t, entry.key, entry.value)
+	}
+	if c.conf.removalListener != nil {
+		c.pending = append(c.pending, removal[K, V]{key: entry.key, value: entry.value, cause: cause})
+	}
+}
+
+// unlock 释放写锁, 并在锁外回调持有锁期间产生的删除通知
+func (c *ClockCache[K, V]) unlock() {
+	pending := c.pending
+	c.pending = nil
+	c.mutex.Unlock()
+	for _, n := range pending {
+		c.conf.removalListener(n.key, n.value, n.cause)
+	}
+}
+
+// inspect 按环中的顺序分页
+func (c *ClockCache[K, V]) inspect(offset, limit int) ([]KeyInfo, int) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	keys := make([]KeyInfo, 0, limit)
+	for i := offset; i < len(c.ring) && len(keys) < limit; i++ {
+		keys = append(keys, KeyInfo{Key: c.ring[i].strKey})
+	}
+	return keys, len(c.ring)
+}
+
+func (c *ClockCache[K, V]) invalidate(_ context.Context, strKey string) (bool, error) {
+	c.mutex.Lock()
+	defer c.unlock()
+
+	return c.remove(strKey, RemovalExplicit), nil
+}
+
+func (c *ClockCache[K, V]) invalidateAll(_ context.Context) error {
+	c.Clear()
+	return nil
+}
+
+func (c *ClockCache[K, V]) stringKey(key any) string {
+	if c.conf.keyToString != nil {
+		k, ok := key.(K)
+		if !ok {
+			panic("key type error " + fmt.Sprint(key))
+		}
+		return c.conf.keyToString(k)
+	}
+	switch data := key.(type) {
+	case string:
+		return data
+	case int, int8, int16, int32, int64, float32, float64, uint8, uint16, uint32, uint64, bool:
+		return fmt.Sprint(key)
+	case fmt.Stringer:
+		return data.String()
+	default:
+		panic("unsupported key type " + fmt.Sprint(key))
+	}
+}
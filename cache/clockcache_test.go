@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+
+	viktor "github.com/myron934/go-viktor"
+)
+
+var _ Inspectable = (*ClockCache[string, int])(nil)
+
+func TestClockCache(t *testing.T) {
+	var evicted []string
+	c := NewClockCache(
+		WithCapacity[string, int](3),
+		WithRecordStats[string, int](),
+		WithRemovalListener[string, int](func(key string, _ *int, cause RemovalCause) {
+			if cause == RemovalEvicted {
+				evicted = append(evicted, key)
+			}
+		}),
+	)
+	c.Put("a", viktor.Ptr(1))
+	c.Put("b", viktor.Ptr(2))
+	c.Put("c", viktor.Ptr(3))
+	// a被访问过, 获得第二次机会, 淘汰b
+	c.Get("a")
+	c.Put("d", viktor.Ptr(4))
+	if c.Contains("b") || !c.Contains("a") || len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("unexpected eviction %v", evicted)
+	}
+	// 扫描清除了a的标记, 下一个淘汰c
+	c.Put("e", viktor.Ptr(5))
+	if c.Contains("c") || c.Size() != 3 {
+		t.Fatal("c should be evicted")
+	}
+
+	c.Remove("a")
+	if c.Contains("a") || c.Size() != 2 || c.MustGet("d") == nil || *c.MustGet("e") != 5 {
+		t.Fatal("remove broke the ring")
+	}
+	c.Resize(1)
+	if c.Size() != 1 || c.Capacity() != 1 {
+		t.Fatal("resize")
+	}
+	stats := c.Stats()
+	if stats.Hits != 3 || stats.Evictions != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	c.Clear()
+	if c.Size() != 0 {
+		t.Fatal("clear")
+	}
+}
+
+func TestClockCacheConcurrent(t *testing.T) {
+	c := NewClockCache(WithCapacity[int, int](100))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for j := 0; j < 10000; j++ {
+				key := r.Intn(200)
+				switch r.Intn(10) {
+				case 0:
+					c.Put(key, viktor.Ptr(key))
+				case 1:
+					c.Remove(key)
+				default:
+					if v, err := c.Get(key); err == nil && *v != key {
+						t.Errorf("key %d has value %d", key, *v)
+						return
+					}
+				}
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	if c.Size() > 100 {
+		t.Fatal("size exceeds capacity", c.Size())
+	}
+}
+
+// benchGetter 并发读写基准测试需要的缓存方法
+type benchGetter interface {
+	Get(key string) (*int, error)
+	Put(key string, value *int)
+}
+
+func benchmarkParallel(b *testing.B, c benchGetter, writePercent int) {
+	const size = 1 << 12
+	keys := make([]string, size)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		c.Put(keys[i], viktor.Ptr(i))
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			key := keys[r.Intn(size)]
+			if r.Intn(100) < writePercent {
+				c.Put(key, viktor.Ptr(0))
+			} else {
+				c.Get(key)
+			}
+		}
+	})
+}
+
+func BenchmarkParallelGet(b *testing.B) {
+	b.Run("LRU", func(b *testing.B) {
+		benchmarkParallel(b, NewLRUCache(WithCapacity[string, int](1<<12)), 0)
+	})
+	b.Run("Clock", func(b *testing.B) {
+		benchmarkParallel(b, NewClockCache(WithCapacity[string, int](1<<12)), 0)
+	})
+}
+
+func BenchmarkParallelMixed(b *testing.B) {
+	b.Run("LRU", func(b *testing.B) {
+		benchmarkParallel(b, NewLRUCache(WithCapacity[string, int](1<<12)), 10)
+	})
+	b.Run("Clock", func(b *testing.B) {
+		benchmarkParallel(b, NewClockCache(WithCapacity[string, int](1<<12)), 10)
+	})
+}
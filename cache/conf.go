@@ -1,14 +1,35 @@
 package cache
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type Config[K, V any] struct {
-	capacity         int
-	expireAfterWrite time.Duration // 过期时间
-	clearInterval    time.Duration // 定时清理过期key的间隔
-	minClearInterval time.Duration // 为了防止缓存满了以后频繁触发清理, 定义最小触发间隔, 该时间内如果已经清理过,则不再清理
-	keyToString      func(key K) string
-	getterFunc       func(key K) (*V, error) //缓存不存在时的获取方法
+	capacity          int
+	expireAfterWrite  time.Duration // 过期时间
+	expireFunc        ExpireFunc[V] // 根据value计算过期时间, 优先于 expireAfterWrite
+	refreshAfterWrite time.Duration // 写入超过该时间后, 下一次访问时在后台重新加载, 0表示不刷新
+	clearInterval     time.Duration // 定时清理过期key的间隔, 0(默认)表示不定时清理
+	minClearInterval  time.Duration // 为了防止缓存满了以后频繁触发清理, 定义最小触发间隔, 该时间内如果已经清理过,则不再清理
+	keyToString       func(key K) string
+	getterFunc        func(key K) (*V, error)                        //缓存不存在时的获取方法
+	loadFunc          LoadFunc[V]                                    // 批量获取方法, 用于预热等批量加载的场景
+	snapshotCodec     *SnapshotCodec[K, V]                           // 快照(SaveTo/LoadFrom)的序列化方式, 为nil时使用json
+	prefixIndex       bool                                           // 是否为key建立前缀索引, 用于 InvalidatePrefix
+	recordStats       bool                                           // 是否记录统计数据, 见 Stats
+	clock             Clock                                          // 时间源, 默认为 SystemClock
+	expireJitter      float64                                        // 过期时间的随机抖动比例, 见 WithExpireJitter
+	earlyRefreshBeta  float64                                        // 提前刷新的系数, 0表示不提前刷新, 见 WithEarlyRefresh
+	maxPinnedRatio    float64                                        // 固定的元素最多占容量的比例, 见 Pinned
+	removalListener   RemovalListener[K, V]                          // 元素被删除或替换后的回调
+	writeBehind       *writeBehindConf[V]                            // 异步写回的配置, 为nil时不写回
+	writer            func(ctx context.Context, key K, val *V) error // 同步写入存储, 见 WithWriter
+	deleter           func(ctx context.Context, key K) error         // 同步从存储删除, 见 WithDeleter
+	hotKeys           int                                            // 统计访问最多的key的数量, 0表示不统计, 见 WithHotKeys
+	hotKeyWindow      time.Duration                                  // 统计访问最多的key的滑动窗口
+	existenceFilter   ExistenceFilter                                // 加载前判断key是否可能存在, 见 WithExistenceFilter
+	eventBuffer       int                                            // 每个事件订阅方的缓冲区大小, 见 WithEventBuffer
 }
 type Option[K, V any] func(conf *Config[K, V]) *Config[K, V]
 
@@ -16,12 +37,16 @@ func NewDefaultConf[K, V any]() *Config[K, V] {
 	return &Config[K, V]{
 		capacity:         100,
 		expireAfterWrite: time.Minute,
-		clearInterval:    time.Minute * 5,
+		clearInterval:    0,
 		minClearInterval: time.Second * 3,
 		keyToString:      nil,
+		clock:            SystemClock,
+		maxPinnedRatio:   1,
+		eventBuffer:      256,
 	}
 }
 
+// WithConfig 直接使用conf作为配置, 之前的 Option 都会被丢弃; 需要组合配置时推荐使用 Builder
 func WithConfig[K, V any](conf *Config[K, V]) Option[K, V] {
 	return func(oldConf *Config[K, V]) *Config[K, V] {
 		return conf
@@ -55,6 +80,17 @@ func WithExpireAfterWrite[K, V any](expireAfterWrite time.Duration) Option[K, V]
 	}
 }
 
+// WithExpireFunc 根据写入的value计算过期时间, 设置后优先于 expireAfterWrite
+// expireFunc 返回值<=0时表示不缓存该value
+func WithExpireFunc[K, V any](expireFunc ExpireFunc[V]) Option[K, V] {
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.expireFunc = expireFunc
+		return conf
+	}
+}
+
+// WithClearInterval 定时在后台清理过期元素的间隔, 缓存为空时不启动定时器
+// 默认为0, 不定时清理, 过期的元素在访问或被淘汰时删除; 开启后需要通过 LoadingCache.Close 停止
 func WithClearInterval[K, V any](clearInterval time.Duration) Option[K, V] {
 	if clearInterval < 0 {
 		panic("clearInterval less than 0")
@@ -65,6 +101,184 @@ func WithClearInterval[K, V any](clearInterval time.Duration) Option[K, V] {
 	}
 }
 
+// WithRefreshAfterWrite 元素写入超过refreshAfterWrite后, 下一次访问时先返回旧值, 并在后台通过 getterFunc 重新加载
+// 应小于过期时间, 未配置 getterFunc 时不生效
+func WithRefreshAfterWrite[K, V any](refreshAfterWrite time.Duration) Option[K, V] {
+	if refreshAfterWrite < 0 {
+		panic("refreshAfterWrite less than 0")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.refreshAfterWrite = refreshAfterWrite
+		return conf
+	}
+}
+
+// WithExpireJitter 过期时间在 [ttl*(1-fraction), ttl*(1+fraction)] 内随机, 避免同时加载的元素同时过期
+// fraction 取值范围 [0, 1)
+func WithExpireJitter[K, V any](fraction float64) Option[K, V] {
+	if fraction < 0 || fraction >= 1 {
+		panic("expire jitter must be in [0, 1)")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.expireJitter = fraction
+		return conf
+	}
+}
+
+// WithEarlyRefresh 开启XFetch概率提前刷新: 通过加载写入的元素在过期前被访问时,
+// 满足 now - loadCost*beta*ln(rand()) >= expire 则在后台重新加载, 加载越慢, 越接近过期, 提前刷新的概率越大.
+// beta 通常取1, 越大越倾向于提前刷新; 未配置 getterFunc 时不生效
+func WithEarlyRefresh[K, V any](beta float64) Option[K, V] {
+	if beta < 0 {
+		panic("early refresh beta less than 0")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.earlyRefreshBeta = beta
+		return conf
+	}
+}
+
+// WithMaxPinnedRatio 固定(Pinned)的元素最多占容量的比例, 取值范围 [0, 1], 默认为1
+func WithMaxPinnedRatio[K, V any](ratio float64) Option[K, V] {
+	if ratio < 0 || ratio > 1 {
+		panic("max pinned ratio must be in [0, 1]")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.maxPinnedRatio = ratio
+		return conf
+	}
+}
+
+// maxPinned 当前容量下最多可以固定的元素数量
+func (conf *Config[K, V]) maxPinned() int {
+	return int(float64(conf.capacity) * conf.maxPinnedRatio)
+}
+
+// WithRemovalListener 元素被删除, 替换, 淘汰或过期删除后回调listener, 见 RemovalListener
+func WithRemovalListener[K, V any](listener RemovalListener[K, V]) Option[K, V] {
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.removalListener = listener
+		return conf
+	}
+}
+
+// WithWriter 开启同步写入(write-through): Put, PutIfAbsent, Compute 等写入操作先调用writer写入存储, 成功后才更新缓存,
+// 失败时缓存保持不变并返回writer的错误; 通过加载写入的数据不会写入存储. 不能与 WithWriteBehind 同时使用
+// 同一个key的写入和删除按key分段串行执行, writer在缓存的全局锁之外调用, 慢的存储只会阻塞同一段的key;
+// writer 和 deleter 中不能写入该缓存, 否则可能因为同一段的锁而死锁. 加载不持有段锁, getterFunc 中可以读取该缓存,
+// 加载期间写入或删除了该key时, 加载的结果不会写入缓存
+func WithWriter[K, V any](writer func(ctx context.Context, key K, val *V) error) Option[K, V] {
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.writer = writer
+		return conf
+	}
+}
+
+// WithDeleter Remove, 以及 Compute 返回不保留时, 先调用deleter从存储删除, 成功后才删除缓存
+// Clear, InvalidateTag 等只删除缓存, 不会调用deleter
+func WithDeleter[K, V any](deleter func(ctx context.Context, key K) error) Option[K, V] {
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.deleter = deleter
+		return conf
+	}
+}
+
+// WithWriteBehind 开启异步写回: Put 等写入操作只更新缓存并把元素标记为脏数据, 由后台任务每隔interval,
+// 或者累计batchSize次写入后, 将脏数据分批(每批最多batchSize个, 0表示不分批)交给writer写入存储; 通过加载写入的数据不会写回.
+// 写回失败时保留脏数据并按 WithWriteBehindBackoff 退避重试; 脏数据被淘汰或过期时立即触发写回, 写回之前加载该key会返回未写回的值.
+// 通过 Remove, Clear 或 Invalidate 等删除的key丢弃未写回的值, 删除本身不会写回, 需要时配合 WithDeleter 使用.
+// 使用完毕后需要调用 LoadingCache.Close 停止后台任务并写回剩余的数据
+func WithWriteBehind[K, V any](writer WriterFunc[V], interval time.Duration, batchSize int) Option[K, V] {
+	if writer == nil || interval <= 0 || batchSize < 0 {
+		panic("invalid write behind config")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		wb := conf.writeBehindConf()
+		wb.writer = writer
+		wb.interval = interval
+		wb.batchSize = batchSize
+		return conf
+	}
+}
+
+// WithWriteBehindBackoff 写回失败后的重试间隔, 从min开始每次失败翻倍, 最大为max, 默认为100ms到30s
+func WithWriteBehindBackoff[K, V any](min, max time.Duration) Option[K, V] {
+	if min <= 0 || max < min {
+		panic("invalid write behind backoff")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		wb := conf.writeBehindConf()
+		wb.minBackoff = min
+		wb.maxBackoff = max
+		return conf
+	}
+}
+
+func (conf *Config[K, V]) writeBehindConf() *writeBehindConf[V] {
+	if conf.writeBehind == nil {
+		conf.writeBehind = &writeBehindConf[V]{
+			minBackoff: time.Millisecond * 100,
+			maxBackoff: time.Second * 30,
+		}
+	}
+	return conf.writeBehind
+}
+
+// WithHotKeys 统计滑动窗口window内访问(Get, GetOrCompute)次数最多的topK个key, 见 LoadingCache.HotKeys
+// 使用固定大小的 count-min sketch 计数, 内存占用与key的数量无关, 次数为估计值
+func WithHotKeys[K, V any](topK int, window time.Duration) Option[K, V] {
+	if topK <= 0 || window <= 0 {
+		panic("invalid hot keys config")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.hotKeys = topK
+		conf.hotKeyWindow = window
+		return conf
+	}
+}
+
+// ExistenceFilter 判断key是否可能存在, 如 bloom.Filter, 实现需要并发安全
+// TestString 返回false时key一定不存在
+type ExistenceFilter interface {
+	TestString(key string) bool
+	AddString(key string)
+}
+
+// WithExistenceFilter 缓存未命中时先通过filter判断key是否可能存在, 一定不存在时直接返回 ErrorKeyNotFound, 不调用 getterFunc,
+// 防止大量请求不存在的key时穿透到存储. filter 通常为 bloom.Filter, 需要预先添加存储中全部的key;
+// 写入缓存的key也会添加到filter中
+func WithExistenceFilter[K, V any](filter ExistenceFilter) Option[K, V] {
+	if filter == nil {
+		panic("existence filter is nil")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.existenceFilter = filter
+		return conf
+	}
+}
+
+// WithEventBuffer 每个 Events 订阅方的缓冲区大小, 默认256, 缓冲区满了以后的事件会被丢弃
+func WithEventBuffer[K, V any](size int) Option[K, V] {
+	if size < 0 {
+		panic("event buffer less than 0")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.eventBuffer = size
+		return conf
+	}
+}
+
+// WithClock 指定时间源, 过期, 刷新以及定时清理都基于该时钟
+func WithClock[K, V any](clock Clock) Option[K, V] {
+	if clock == nil {
+		panic("clock is nil")
+	}
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.clock = clock
+		return conf
+	}
+}
+
 func WithMinClearInterval[K, V any](minClearInterval time.Duration) Option[K, V] {
 	if minClearInterval < 0 {
 		panic("minClearInterval less than 0")
@@ -80,3 +294,37 @@ func WithGetterFunc[K, V any](getterFunc func(key K) (*V, error)) Option[K, V] {
 		return conf
 	}
 }
+
+// WithLoadFunc 指定批量获取方法
+// loadFunc 的参数为需要加载的key, 返回的map以key为键, 缺失的key视为不存在
+func WithLoadFunc[K, V any](loadFunc LoadFunc[V]) Option[K, V] {
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.loadFunc = loadFunc
+		return conf
+	}
+}
+
+// WithPrefixIndex 为编码后的key建立前缀索引, InvalidatePrefix 的开销与受影响的元素数量成正比
+// 未开启时 InvalidatePrefix 需要遍历全部元素
+func WithPrefixIndex[K, V any]() Option[K, V] {
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.prefixIndex = true
+		return conf
+	}
+}
+
+// WithRecordStats 记录命中率, 加载耗时, 淘汰数量等统计数据
+func WithRecordStats[K, V any]() Option[K, V] {
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.recordStats = true
+		return conf
+	}
+}
+
+// WithSnapshotCodec 指定 SaveTo / LoadFrom 时key和value的序列化方式
+func WithSnapshotCodec[K, V any](codec *SnapshotCodec[K, V]) Option[K, V] {
+	return func(conf *Config[K, V]) *Config[K, V] {
+		conf.snapshotCodec = codec
+		return conf
+	}
+}
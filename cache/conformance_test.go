@@ -0,0 +1,121 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/myron934/go-viktor/cache"
+	"github.com/myron934/go-viktor/cache/cachetest"
+)
+
+type lruAdapter struct{ c *cache.LRUCache[string, int] }
+
+func (a lruAdapter) Get(key string) (int, bool) {
+	v, err := a.c.Get(key)
+	if err != nil {
+		return 0, false
+	}
+	return *v, true
+}
+func (a lruAdapter) Put(key string, value int) { a.c.Put(key, &value) }
+func (a lruAdapter) Remove(key string)         { a.c.Remove(key) }
+func (a lruAdapter) Len() int                  { return a.c.Size() }
+
+type lfuAdapter struct{ c *cache.LFUCache[int] }
+
+func (a lfuAdapter) Get(key string) (int, bool) {
+	v := a.c.Get(key)
+	if v == nil {
+		return 0, false
+	}
+	return *v, true
+}
+func (a lfuAdapter) Put(key string, value int) { a.c.Put(key, &value) }
+func (a lfuAdapter) Remove(key string) {
+	// LFUCache 没有 Remove, 通过不保留的 ComputeIfPresent 删除
+	a.c.ComputeIfPresent(key, func(*int) (*int, bool) { return nil, false })
+}
+func (a lfuAdapter) Len() int { return a.c.Size() }
+
+type clockAdapter struct {
+	c *cache.ClockCache[string, int]
+}
+
+func (a clockAdapter) Get(key string) (int, bool) {
+	v, err := a.c.Get(key)
+	if err != nil {
+		return 0, false
+	}
+	return *v, true
+}
+func (a clockAdapter) Put(key string, value int) { a.c.Put(key, &value) }
+func (a clockAdapter) Remove(key string)         { a.c.Remove(key) }
+func (a clockAdapter) Len() int                  { return a.c.Size() }
+
+type loadingAdapter struct {
+	c *cache.LoadingCache[string, int]
+}
+
+func (a loadingAdapter) Get(key string) (int, bool) {
+	v, err := a.c.Get(context.Background(), key)
+	if err != nil {
+		return 0, false
+	}
+	return *v, true
+}
+func (a loadingAdapter) Put(key string, value int) { a.c.Put(context.Background(), key, &value) }
+func (a loadingAdapter) Remove(key string)         { a.c.Remove(context.Background(), key) }
+func (a loadingAdapter) Len() int                  { return a.c.Size() }
+
+func TestConformanceLRU(t *testing.T) {
+	cachetest.Suite{
+		New: func(capacity int, _ *cachetest.FakeClock) cachetest.Cache {
+			return lruAdapter{cache.NewLRUCache(cache.WithCapacity[string, int](capacity))}
+		},
+		NewModel: cachetest.NewLRUModel,
+	}.Run(t)
+}
+
+func TestConformanceLFU(t *testing.T) {
+	cachetest.Suite{
+		New: func(capacity int, _ *cachetest.FakeClock) cachetest.Cache {
+			return lfuAdapter{cache.NewLFUCache[int](capacity)}
+		},
+	}.Run(t)
+}
+
+func TestConformanceClock(t *testing.T) {
+	cachetest.Suite{
+		New: func(capacity int, _ *cachetest.FakeClock) cachetest.Cache {
+			return clockAdapter{cache.NewClockCache(cache.WithCapacity[string, int](capacity))}
+		},
+	}.Run(t)
+}
+
+func TestConformanceLoadingCache(t *testing.T) {
+	cachetest.Suite{
+		New: func(capacity int, _ *cachetest.FakeClock) cachetest.Cache {
+			return loadingAdapter{cache.NewLoadingCache(
+				cache.WithCapacity[string, int](capacity),
+				cache.WithExpireAfterWrite[string, int](time.Hour),
+			)}
+		},
+		NewModel: cachetest.NewLRUModel,
+	}.Run(t)
+}
+
+func TestConformanceLoadingCacheExpire(t *testing.T) {
+	cachetest.Suite{
+		New: func(capacity int, clock *cachetest.FakeClock) cachetest.Cache {
+			return loadingAdapter{cache.NewLoadingCache(
+				cache.WithClock[string, int](clock),
+				cache.WithCapacity[string, int](capacity),
+				cache.WithExpireAfterWrite[string, int](time.Minute),
+				cache.WithClearInterval[string, int](time.Second*30),
+				cache.WithMinClearInterval[string, int](0),
+			)}
+		},
+		TTL: time.Minute,
+	}.Run(t)
+}
@@ -2,4 +2,15 @@ package cache
 
 import "errors"
 
-var ErrorKeyNotFound = errors.New("key not found")
+var (
+	ErrorKeyNotFound         = errors.New("key not found")
+	ErrorNoLoader            = errors.New("no loader configured")
+	ErrorBusAttached         = errors.New("cache is already attached to an invalidation bus")
+	ErrorSnapshotFormat      = errors.New("invalid snapshot format")
+	ErrorSnapshotVersion     = errors.New("unsupported snapshot version")
+	ErrorSnapshotKind        = errors.New("snapshot was saved by another kind of cache")
+	ErrorSnapshotSerialize   = errors.New("snapshot serialize failed")
+	ErrorSnapshotDeserialize = errors.New("snapshot deserialize failed")
+	ErrorInvalidSpec         = errors.New("invalid cache spec")
+	ErrorInvalidConfig       = errors.New("invalid cache config")
+)
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType 缓存事件的类型
+type EventType int
+
+const (
+	EventPut    EventType = iota + 1 // 通过 Put 等方法写入, 不包括加载
+	EventHit                         // 读取命中
+	EventMiss                        // 读取未命中
+	EventLoad                        // 加载完成, 失败时 Event.Err 不为nil
+	EventEvict                       // 因容量不足被淘汰
+	EventExpire                      // 过期被删除
+	EventRemove                      // 被主动删除
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventLoad:
+		return "load"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	case EventRemove:
+		return "remove"
+	default:
+		return "unknown"
+	}
+}
+
+// Event 缓存事件, Miss 事件的 Value 为nil
+type Event[K, V any] struct {
+	Type  EventType
+	Key   K
+	Value *V
+	Time  time.Time
+	Err   error
+}
+
+// EventFilter 返回true的事件才会发送, 为nil时发送全部事件
+type EventFilter[K, V any] func(event Event[K, V]) bool
+
+// OnlyEvents 只发送指定类型的事件
+func OnlyEvents[K, V any](types ...EventType) EventFilter[K, V] {
+	return func(event Event[K, V]) bool {
+		for _, t := range types {
+			if event.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// eventHub 把事件分发给订阅方, 订阅方的缓冲区满了时丢弃事件并计数, 不会阻塞缓存的操作
+// int64字段放在最前面, 保证32位平台上原子操作的对齐
+type eventHub[K, V any] struct {
+	dropped     int64
+	subscribers int32
+	mutex       sync.RWMutex
+	subs        map[*eventSub[K, V]]struct{}
+}
+
+type eventSub[K, V any] struct {
+	ch     chan Event[K, V]
+	filter EventFilter[K, V]
+}
+
+// active 是否有订阅方, 没有时调用方不需要构造事件
+func (h *eventHub[K, V]) active() bool {
+	return atomic.LoadInt32(&h.subscribers) > 0
+}
+
+// subscribe 订阅事件, ctx结束后取消订阅并关闭channel
+func (h *eventHub[K, V]) subscribe(ctx context.Context, filter EventFilter[K, V], buffer int) <-chan Event[K, V] {
+	sub := &eventSub[K, V]{ch: make(chan Event[K, V], buffer), filter: filter}
+	h.mutex.Lock()
+	if h.subs == nil {
+		h.subs = make(map[*eventSub[K, V]]struct{})
+	}
+	h.subs[sub] = struct{}{}
+	atomic.AddInt32(&h.subscribers, 1)
+	h.mutex.Unlock()
+	go func() {
+		<-ctx.Done()
+		h.mutex.Lock()
+		delete(h.subs, sub)
+		atomic.AddInt32(&h.subscribers, -1)
+		close(sub.ch)
+		h.mutex.Unlock()
+	}()
+	return sub.ch
+}
+
+func (h *eventHub[K, V]) publish(event Event[K, V]) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for sub := range h.subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			atomic.AddInt64(&h.dropped, 1)
+		}
+	}
+}
+
+func (h *eventHub[K, V]) droppedCount() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+func removalEvent(cause RemovalCause) (EventType, bool) {
+	switch cause {
+	case RemovalEvicted:
+		return EventEvict, true
+	case RemovalExpired:
+		return EventExpire, true
+	case RemovalExplicit:
+		return EventRemove, true
+	default:
+		return 0, false
+	}
+}
+
+// Events 订阅缓存事件, ctx结束后channel被关闭. filter为nil时订阅全部事件
+// 事件在缓存的操作中以非阻塞的方式发送, 缓冲区(见 WithEventBuffer)满了时丢弃, 见 DroppedEvents;
+// filter 在缓存的锁内调用, 不能访问该缓存
+func (c *LoadingCache[K, V]) Events(ctx context.Context, filter EventFilter[K, V]) <-chan Event[K, V] {
+	return c.events.subscribe(ctx, filter, c.conf.eventBuffer)
+}
+
+// DroppedEvents 因订阅方处理不及时被丢弃的事件数量
+func (c *LoadingCache[K, V]) DroppedEvents() int64 {
+	return c.events.droppedCount()
+}
+
+func (c *LoadingCache[K, V]) emit(t EventType, key K, value *V, err error) {
+	if c.events.active() {
+		c.events.publish(Event[K, V]{Type: t, Key: key, Value: value, Time: c.now(), Err: err})
+	}
+}
+
+// Events 订阅缓存事件, 见 LoadingCache.Events. LRUCache 没有 Load 和 Expire 事件
+func (lru *LRUCache[K, V]) Events(ctx context.Context, filter EventFilter[K, V]) <-chan Event[K, V] {
+	return lru.events.subscribe(ctx, filter, lru.conf.eventBuffer)
+}
+
+// DroppedEvents 因订阅方处理不及时被丢弃的事件数量
+func (lru *LRUCache[K, V]) DroppedEvents() int64 {
+	return lru.events.droppedCount()
+}
+
+func (lru *LRUCache[K, V]) emit(t EventType, key K, value *V) {
+	if lru.events.active() {
+		lru.events.publish(Event[K, V]{Type: t, Key: key, Value: value, Time: lru.conf.clock.Now()})
+	}
+}
+
+// Events 订阅缓存事件, 见 LoadingCache.Events. LFUCache 没有 Load 和 Expire 事件, Key 为写入时的key
+func (lfu *LFUCache[V]) Events(ctx context.Context, filter EventFilter[any, V]) <-chan Event[any, V] {
+	return lfu.events.subscribe(ctx, filter, lfu.conf.eventBuffer)
+}
+
+// DroppedEvents 因订阅方处理不及时被丢弃的事件数量
+func (lfu *LFUCache[V]) DroppedEvents() int64 {
+	return lfu.events.droppedCount()
+}
+
+func (lfu *LFUCache[V]) emit(t EventType, key any, value *V) {
+	if lfu.events.active() {
+		lfu.events.publish(Event[any, V]{Type: t, Key: key, Value: value, Time: lfu.conf.clock.Now()})
+	}
+}
+
+// Events 订阅缓存事件, 见 LoadingCache.Events. ClockCache 没有 Load 和 Expire 事件;
+// Hit 和 Miss 事件在读锁下发送, 多个读操作的事件顺序不确定
+func (c *ClockCache[K, V]) Events(ctx context.Context, filter EventFilter[K, V]) <-chan Event[K, V] {
+	return c.events.subscribe(ctx, filter, c.conf.eventBuffer)
+}
+
+// DroppedEvents 因订阅方处理不及时被丢弃的事件数量
+func (c *ClockCache[K, V]) DroppedEvents() int64 {
+	return c.events.droppedCount()
+}
+
+func (c *ClockCache[K, V]) emit(t EventType, key K, value *V) {
+	if c.events.active() {
+		c.events.publish(Event[K, V]{Type: t, Key: key, Value: value, Time: c.conf.clock.Now()})
+	}
+}
@@ -0,0 +1,109 @@
+package cache_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+	"github.com/myron934/go-viktor/cache"
+	"github.com/myron934/go-viktor/cache/cachetest"
+)
+
+// drain 读取channel中已有的事件
+func drain[K, V any](ch <-chan cache.Event[K, V]) []cache.EventType {
+	var types []cache.EventType
+	for {
+		select {
+		case event := <-ch:
+			types = append(types, event.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := cachetest.NewFakeClock(time.Now())
+	c := cache.NewLoadingCache(
+		cache.WithClock[string, int](clock),
+		cache.WithCapacity[string, int](1),
+		cache.WithExpireAfterWrite[string, int](time.Minute),
+		cache.WithClearInterval[string, int](0),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			return viktor.Ptr(len(key)), nil
+		}),
+	)
+	events := c.Events(ctx, nil)
+	loads := c.Events(ctx, cache.OnlyEvents[string, int](cache.EventLoad))
+
+	c.Put(ctx, "a", viktor.Ptr(1))
+	c.Get(ctx, "a")
+	c.Get(ctx, "bb")
+	c.Remove(ctx, "bb")
+	want := []cache.EventType{cache.EventPut, cache.EventHit, cache.EventMiss, cache.EventLoad, cache.EventEvict, cache.EventRemove}
+	if got := drain(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	c.Put(ctx, "c", viktor.Ptr(1))
+	clock.Advance(time.Minute)
+	c.Get(ctx, "c")
+	want = []cache.EventType{cache.EventPut, cache.EventMiss, cache.EventLoad, cache.EventExpire}
+	if got := drain(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := drain(loads); len(got) != 2 {
+		t.Fatalf("filter should only pass loads, got %v", got)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestEventsDropped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := cache.NewLRUCache(cache.WithEventBuffer[string, int](1))
+	events := c.Events(ctx, nil)
+	for i := 0; i < 3; i++ {
+		c.Put("a", viktor.Ptr(i))
+	}
+	if c.DroppedEvents() != 2 {
+		t.Fatalf("want 2 dropped events, got %d", c.DroppedEvents())
+	}
+	if event := <-events; event.Type != cache.EventPut || event.Key != "a" || *event.Value != 0 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+}
+
+func TestEventsLFUAndClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lfu := cache.NewLFUCache[int](1)
+	lfuEvents := lfu.Events(ctx, nil)
+	lfu.Put("a", viktor.Ptr(1))
+	lfu.Get("a")
+	lfu.Get("b")
+	lfu.Put("b", viktor.Ptr(2))
+	want := []cache.EventType{cache.EventPut, cache.EventHit, cache.EventMiss, cache.EventEvict, cache.EventPut}
+	if got := drain(lfuEvents); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	clock := cache.NewClockCache(cache.WithCapacity[string, int](1))
+	clockEvents := clock.Events(ctx, cache.OnlyEvents[string, int](cache.EventEvict, cache.EventRemove))
+	clock.Put("a", viktor.Ptr(1))
+	clock.Put("b", viktor.Ptr(2))
+	clock.Remove("b")
+	want = []cache.EventType{cache.EventEvict, cache.EventRemove}
+	if got := drain(clockEvents); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if lfu.DroppedEvents() != 0 || clock.DroppedEvents() != 0 {
+		t.Fatal("no events should be dropped")
+	}
+}
@@ -0,0 +1,41 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	viktor "github.com/myron934/go-viktor"
+	"github.com/myron934/go-viktor/bloom"
+	"github.com/myron934/go-viktor/cache"
+)
+
+func TestExistenceFilter(t *testing.T) {
+	ctx := context.Background()
+	filter := bloom.New(100, 0.001)
+	filter.AddString("u1")
+	loads := 0
+	c := cache.NewLoadingCache(
+		cache.WithExistenceFilter[string, int](filter),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			loads++
+			return viktor.Ptr(1), nil
+		}),
+	)
+	if v, err := c.Get(ctx, "u1"); err != nil || *v != 1 || loads != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := c.Get(ctx, "missing"); !errors.Is(err, cache.ErrorKeyNotFound) || loads != 1 {
+		t.Fatal("absent key should not reach the getter", err, loads)
+	}
+	if _, err := c.GetOrCompute(ctx, "missing", nil); !errors.Is(err, cache.ErrorKeyNotFound) || loads != 1 {
+		t.Fatal("absent key should not reach the getter", err, loads)
+	}
+
+	// 写入的key会加入过滤器, 被删除后仍然可以加载
+	c.Put(ctx, "u2", viktor.Ptr(2))
+	c.Remove(ctx, "u2")
+	if _, err := c.Get(ctx, "u2"); err != nil || loads != 2 {
+		t.Fatal(err, loads)
+	}
+}
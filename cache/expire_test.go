@@ -0,0 +1,115 @@
+package cache_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+	"github.com/myron934/go-viktor/cache"
+	"github.com/myron934/go-viktor/cache/cachetest"
+)
+
+func TestExpireJitter(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	c := cache.NewLoadingCache(
+		cache.WithClock[int, int](clock),
+		cache.WithCapacity[int, int](1000),
+		cache.WithExpireAfterWrite[int, int](100*time.Second),
+		cache.WithExpireJitter[int, int](0.2),
+		cache.WithClearInterval[int, int](0),
+	)
+	for i := 0; i < 1000; i++ {
+		c.Put(ctx, i, viktor.Ptr(i))
+	}
+	clock.Advance(80 * time.Second)
+	if n := len(c.Keys()); n != 1000 {
+		t.Fatalf("%d keys left before the minimum ttl, want 1000", n)
+	}
+	clock.Advance(20 * time.Second)
+	if n := len(c.Keys()); n == 0 || n == 1000 {
+		t.Fatalf("%d keys left at the base ttl, want the expiry spread out", n)
+	}
+	clock.Advance(20 * time.Second)
+	if n := len(c.Keys()); n != 0 {
+		t.Fatalf("%d keys left after the maximum ttl, want 0", n)
+	}
+}
+
+func TestEarlyRefresh(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	var version int32
+	c := cache.NewLoadingCache(
+		cache.WithClock[string, int32](clock),
+		cache.WithExpireAfterWrite[string, int32](time.Minute),
+		cache.WithEarlyRefresh[string, int32](1),
+		cache.WithClearInterval[string, int32](0),
+		cache.WithGetterFunc[string, int32](func(string) (*int32, error) {
+			v := atomic.AddInt32(&version, 1)
+			if v == 1 {
+				// 第一次加载耗时10s
+				clock.Advance(10 * time.Second)
+			}
+			return viktor.Ptr(v), nil
+		}),
+	)
+	c.Get(ctx, "k")
+
+	// 距离过期1s, 加载耗时10s, 每次访问提前刷新的概率约为90%
+	clock.Advance(59 * time.Second)
+	for i := 0; i < 50 && atomic.LoadInt32(&version) == 1; i++ {
+		if v := c.MustGet(ctx, "k"); *v != 1 {
+			t.Fatalf("got %d, want the old value while refreshing", *v)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if v, ok := c.Peek(ctx, "k"); ok && *v == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("key was not refreshed before expiry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 通过Put写入的元素没有加载耗时, 不会提前刷新
+	c.Put(ctx, "p", viktor.Ptr(int32(100)))
+	clock.Advance(59 * time.Second)
+	for i := 0; i < 50; i++ {
+		c.Get(ctx, "p")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if v := c.MustGet(ctx, "p"); *v != 100 {
+		t.Fatalf("put value was refreshed early: %d", *v)
+	}
+}
+
+func TestExpiredRemovalCause(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	var causes []cache.RemovalCause
+	c := cache.NewLoadingCache(
+		cache.WithClock[string, int](clock),
+		cache.WithCapacity[string, int](1),
+		cache.WithExpireAfterWrite[string, int](time.Minute),
+		cache.WithClearInterval[string, int](2*time.Minute),
+		cache.WithRemovalListener[string, int](func(key string, value *int, cause cache.RemovalCause) {
+			causes = append(causes, cause)
+		}),
+	)
+	defer c.Close(ctx)
+	c.Put(ctx, "a", viktor.Ptr(1))
+	clock.Advance(time.Minute)
+	// 已过期的元素被淘汰
+	c.Put(ctx, "b", viktor.Ptr(2))
+	// 定时清理
+	clock.Advance(2 * time.Minute)
+	if len(causes) != 2 || causes[0] != cache.RemovalExpired || causes[1] != cache.RemovalExpired {
+		t.Fatalf("causes = %v, want [expired expired]", causes)
+	}
+}
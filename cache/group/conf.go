@@ -0,0 +1,81 @@
+package group
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Config[V any] struct {
+	capacity     int           // 本节点负责的key的缓存容量
+	expire       time.Duration // 本节点负责的key的过期时间
+	hotCapacity  int           // 热点远程key的本地镜像容量
+	hotExpire    time.Duration // 热点远程key的本地镜像过期时间
+	hotThreshold int           // 远程key在计数窗口内被访问多少次以后镜像到本地
+	marshal      func(val *V) ([]byte, error)
+	unmarshal    func(data []byte) (*V, error)
+}
+
+type Option[V any] func(conf *Config[V]) *Config[V]
+
+func NewDefaultConf[V any]() *Config[V] {
+	return &Config[V]{
+		capacity:     1000,
+		expire:       time.Minute,
+		hotCapacity:  100,
+		hotExpire:    time.Second * 10,
+		hotThreshold: 3,
+		marshal: func(val *V) ([]byte, error) {
+			return json.Marshal(val)
+		},
+		unmarshal: func(data []byte) (*V, error) {
+			val := new(V)
+			if err := json.Unmarshal(data, val); err != nil {
+				return nil, err
+			}
+			return val, nil
+		},
+	}
+}
+
+func WithCapacity[V any](capacity int) Option[V] {
+	if capacity < 0 {
+		panic("capacity less than 0")
+	}
+	return func(conf *Config[V]) *Config[V] {
+		conf.capacity = capacity
+		return conf
+	}
+}
+
+func WithExpire[V any](expire time.Duration) Option[V] {
+	if expire < 0 {
+		panic("expire less than 0")
+	}
+	return func(conf *Config[V]) *Config[V] {
+		conf.expire = expire
+		return conf
+	}
+}
+
+// WithHotCache 配置热点远程key的本地镜像
+// capacity 为0时不镜像; threshold 远程key被访问多少次以后镜像到本地
+func WithHotCache[V any](capacity int, expire time.Duration, threshold int) Option[V] {
+	if capacity < 0 || expire < 0 || threshold < 1 {
+		panic("invalid hot cache config")
+	}
+	return func(conf *Config[V]) *Config[V] {
+		conf.hotCapacity = capacity
+		conf.hotExpire = expire
+		conf.hotThreshold = threshold
+		return conf
+	}
+}
+
+// WithCodec 指定节点之间传输value时的序列化方式, 默认使用json
+func WithCodec[V any](marshal func(val *V) ([]byte, error), unmarshal func(data []byte) (*V, error)) Option[V] {
+	return func(conf *Config[V]) *Config[V] {
+		conf.marshal = marshal
+		conf.unmarshal = unmarshal
+		return conf
+	}
+}
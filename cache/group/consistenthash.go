@@ -0,0 +1,64 @@
+package group
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// HashFunc 一致性哈希使用的哈希函数
+type HashFunc func(data []byte) uint32
+
+// Ring 带虚拟节点的一致性哈希环, 非并发安全
+type Ring struct {
+	hash     HashFunc
+	replicas int
+	keys     []uint32 // 已排序的虚拟节点哈希值
+	nodes    map[uint32]string
+}
+
+// NewRing 新建一致性哈希环
+// replicas 每个节点的虚拟节点数量, hash 为nil时使用 crc32.ChecksumIEEE
+func NewRing(replicas int, hash HashFunc) *Ring {
+	if replicas <= 0 {
+		panic("replicas must be greater than 0")
+	}
+	if hash == nil {
+		hash = crc32.ChecksumIEEE
+	}
+	return &Ring{
+		hash:     hash,
+		replicas: replicas,
+		nodes:    make(map[uint32]string),
+	}
+}
+
+// IsEmpty 环上是否没有节点
+func (r *Ring) IsEmpty() bool {
+	return len(r.keys) == 0
+}
+
+// Add 添加节点
+func (r *Ring) Add(nodes ...string) {
+	for _, node := range nodes {
+		for i := 0; i < r.replicas; i++ {
+			hash := r.hash([]byte(strconv.Itoa(i) + node))
+			r.keys = append(r.keys, hash)
+			r.nodes[hash] = node
+		}
+	}
+	sort.Slice(r.keys, func(i, j int) bool { return r.keys[i] < r.keys[j] })
+}
+
+// Get 获取key所属的节点, 环为空时返回空字符串
+func (r *Ring) Get(key string) string {
+	if r.IsEmpty() {
+		return ""
+	}
+	hash := r.hash([]byte(key))
+	idx := sort.Search(len(r.keys), func(i int) bool { return r.keys[i] >= hash })
+	if idx == len(r.keys) {
+		idx = 0
+	}
+	return r.nodes[r.keys[idx]]
+}
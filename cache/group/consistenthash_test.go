@@ -0,0 +1,49 @@
+package group
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	// 哈希值直接取数字本身, 便于验证
+	ring := NewRing(3, func(data []byte) uint32 {
+		n, err := strconv.Atoi(string(data))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(n)
+	})
+	if ring.Get("1") != "" {
+		t.Fatal("empty ring should return empty node")
+	}
+	// 虚拟节点: 2, 4, 6, 12, 14, 16, 22, 24, 26
+	ring.Add("6", "4", "2")
+	cases := map[string]string{"2": "2", "11": "2", "23": "4", "27": "2"}
+	for key, want := range cases {
+		if got := ring.Get(key); got != want {
+			t.Errorf("Get(%s)=%s, want %s", key, got, want)
+		}
+	}
+	// 新增 8, 18, 28
+	ring.Add("8")
+	if got := ring.Get("27"); got != "8" {
+		t.Errorf("Get(27)=%s, want 8", got)
+	}
+}
+
+func TestRingDistribution(t *testing.T) {
+	ring := NewRing(50, nil)
+	nodes := []string{"http://a", "http://b", "http://c"}
+	ring.Add(nodes...)
+	counts := make(map[string]int)
+	for i := 0; i < 30000; i++ {
+		counts[ring.Get(fmt.Sprintf("key-%d", i))]++
+	}
+	for _, node := range nodes {
+		if counts[node] < 5000 {
+			t.Errorf("node %s got %d keys, distribution is too skewed: %v", node, counts[node], counts)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package group
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/myron934/go-viktor/cache"
+	"github.com/myron934/go-viktor/concurrency"
+)
+
+// Getter 本节点负责的key不在缓存中时的获取方法, 返回 cache.ErrorKeyNotFound 表示数据不存在
+type Getter[V any] func(ctx context.Context, key string) (*V, error)
+
+// Stats 分组的统计数据
+type Stats struct {
+	Gets           int64 // Get 调用次数
+	LocalLoads     int64 // 调用 Getter 的次数
+	PeerLoads      int64 // 从其他节点获取的次数
+	PeerErrors     int64 // 从其他节点获取失败的次数, 失败后会退化为本地加载
+	HotHits        int64 // 命中热点镜像的次数
+	ServerRequests int64 // 响应其他节点请求的次数
+}
+
+// Group 分布式缓存分组
+// 每个key由一致性哈希选出的节点负责, 负责的节点通过 LoadingCache 加载并缓存, 同一个key的并发加载只执行一次;
+// 其他节点的 Get 会通过HTTP转发给负责的节点, 被频繁访问的远程key会在本地短暂镜像
+type Group[V any] struct {
+	name       string
+	pool       *HTTPPool
+	getter     Getter[V]
+	conf       *Config[V]
+	mainCache  *cache.LoadingCache[string, V]
+	hotCache   *cache.LoadingCache[string, V]
+	remoteHits *cache.LRUCache[string, int]
+	flight     concurrency.SingleFlight[V]
+	stats      Stats
+}
+
+// NewGroup 新建分组并注册到pool, 同一个pool中分组名不能重复, 各节点上同一个分组应使用相同的名字
+func NewGroup[V any](name string, pool *HTTPPool, getter Getter[V], opts ...Option[V]) *Group[V] {
+	if getter == nil {
+		panic("getter is nil")
+	}
+	g := &Group[V]{
+		name:   name,
+		pool:   pool,
+		getter: getter,
+		conf:   NewDefaultConf[V](),
+	}
+	for _, opt := range opts {
+		g.conf = opt(g.conf)
+	}
+	g.mainCache = cache.NewLoadingCache[string, V](
+		cache.WithCapacity[string, V](g.conf.capacity),
+		cache.WithExpireAfterWrite[string, V](g.conf.expire),
+	)
+	if g.conf.hotCapacity > 0 {
+		g.hotCache = cache.NewLoadingCache[string, V](
+			cache.WithCapacity[string, V](g.conf.hotCapacity),
+			cache.WithExpireAfterWrite[string, V](g.conf.hotExpire),
+		)
+		g.remoteHits = cache.NewLRUCache[string, int](cache.WithCapacity[string, int](g.conf.hotCapacity * 4))
+	}
+	pool.register(name, g)
+	return g
+}
+
+// Name 分组名
+func (g *Group[V]) Name() string {
+	return g.name
+}
+
+// Get 获取数据, 本节点负责的key在本地加载, 否则从负责的节点获取
+func (g *Group[V]) Get(ctx context.Context, key string) (*V, error) {
+	atomic.AddInt64(&g.stats.Gets, 1)
+	owner := g.pool.Owner(key)
+	if owner == g.pool.Self() {
+		return g.getLocally(ctx, key)
+	}
+	if g.hotCache != nil {
+		if val, ok := g.hotCache.Peek(ctx, key); ok {
+			atomic.AddInt64(&g.stats.HotHits, 1)
+			return val, nil
+		}
+	}
+	val, err, _ := g.flight.Do(key, func() (*V, error) {
+		return g.getFromPeer(ctx, owner, key)
+	})
+	if err == nil || err == cache.ErrorKeyNotFound {
+		return val, err
+	}
+	// 负责的节点不可用时在本地加载, 不写入本地缓存
+	atomic.AddInt64(&g.stats.PeerErrors, 1)
+	atomic.AddInt64(&g.stats.LocalLoads, 1)
+	return g.getter(ctx, key)
+}
+
+// Remove 删除本节点缓存的key, 包括热点镜像. 不会通知其他节点
+func (g *Group[V]) Remove(ctx context.Context, key string) {
+	g.mainCache.Remove(ctx, key)
+	if g.hotCache != nil {
+		g.hotCache.Remove(ctx, key)
+	}
+}
+
+// Stats 获取统计数据
+func (g *Group[V]) Stats() Stats {
+	return Stats{
+		Gets:           atomic.LoadInt64(&g.stats.Gets),
+		LocalLoads:     atomic.LoadInt64(&g.stats.LocalLoads),
+		PeerLoads:      atomic.LoadInt64(&g.stats.PeerLoads),
+		PeerErrors:     atomic.LoadInt64(&g.stats.PeerErrors),
+		HotHits:        atomic.LoadInt64(&g.stats.HotHits),
+		ServerRequests: atomic.LoadInt64(&g.stats.ServerRequests),
+	}
+}
+
+// getLocally 通过本地的 LoadingCache 获取, 同一个key的并发加载只执行一次
+func (g *Group[V]) getLocally(ctx context.Context, key string) (*V, error) {
+	return g.mainCache.GetOrCompute(ctx, key, func(key string) (*V, error) {
+		atomic.AddInt64(&g.stats.LocalLoads, 1)
+		return g.getter(ctx, key)
+	})
+}
+
+// getLocalEncoded 响应其他节点的请求
+func (g *Group[V]) getLocalEncoded(ctx context.Context, key string) ([]byte, error) {
+	atomic.AddInt64(&g.stats.ServerRequests, 1)
+	val, err := g.getLocally(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return g.conf.marshal(val)
+}
+
+func (g *Group[V]) getFromPeer(ctx context.Context, peer, key string) (*V, error) {
+	data, err := g.pool.fetch(ctx, peer, g.name, key)
+	if err != nil {
+		return nil, err
+	}
+	val, err := g.conf.unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&g.stats.PeerLoads, 1)
+	g.recordRemoteHit(ctx, key, val)
+	return val, nil
+}
+
+// recordRemoteHit 记录远程key的访问次数, 达到阈值后镜像到本地
+func (g *Group[V]) recordRemoteHit(ctx context.Context, key string, val *V) {
+	if g.hotCache == nil {
+		return
+	}
+	count, _ := g.remoteHits.Compute(key, func(old *int, ok bool) (*int, bool) {
+		n := 1
+		if ok {
+			n = *old + 1
+		}
+		return &n, true
+	})
+	if count != nil && *count >= g.conf.hotThreshold {
+		g.remoteHits.Remove(key)
+		g.hotCache.Put(ctx, key, val)
+	}
+}
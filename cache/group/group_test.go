@@ -0,0 +1,155 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/myron934/go-viktor/cache"
+)
+
+type testNode struct {
+	server *httptest.Server
+	pool   *HTTPPool
+	group  *Group[string]
+	loads  int32
+}
+
+func newTestCluster(t *testing.T, n int, opts ...Option[string]) []*testNode {
+	nodes := make([]*testNode, 0, n)
+	addrs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		node := &testNode{}
+		node.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			node.pool.ServeHTTP(w, r)
+		}))
+		t.Cleanup(node.server.Close)
+		node.pool = NewHTTPPool(node.server.URL)
+		node.group = NewGroup[string]("users", node.pool, func(ctx context.Context, key string) (*string, error) {
+			atomic.AddInt32(&node.loads, 1)
+			if key == "missing" {
+				return nil, cache.ErrorKeyNotFound
+			}
+			time.Sleep(time.Millisecond * 10)
+			val := "value-of-" + key
+			return &val, nil
+		}, opts...)
+		nodes = append(nodes, node)
+		addrs = append(addrs, node.server.URL)
+	}
+	for _, node := range nodes {
+		node.pool.Set(addrs...)
+	}
+	return nodes
+}
+
+func findNode(nodes []*testNode, addr string) *testNode {
+	for _, node := range nodes {
+		if node.server.URL == addr {
+			return node
+		}
+	}
+	return nil
+}
+
+func TestGroupForwardToOwner(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 3, WithHotCache[string](0, 0, 1))
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		for _, node := range nodes {
+			val, err := node.group.Get(ctx, key)
+			if err != nil || *val != "value-of-"+key {
+				t.Fatalf("Get(%s)=%v, %v", key, val, err)
+			}
+		}
+	}
+	var totalLoads int32
+	for _, node := range nodes {
+		totalLoads += atomic.LoadInt32(&node.loads)
+		stats := node.group.Stats()
+		if stats.PeerLoads == 0 || stats.ServerRequests == 0 {
+			t.Errorf("node %s did not exchange data with peers: %+v", node.server.URL, stats)
+		}
+	}
+	// 每个key只在负责的节点加载一次
+	if totalLoads != 30 {
+		t.Fatalf("total loads=%d, want 30", totalLoads)
+	}
+
+	if _, err := nodes[0].group.Get(ctx, "missing"); !errors.Is(err, cache.ErrorKeyNotFound) {
+		t.Fatalf("err=%v, want ErrorKeyNotFound", err)
+	}
+}
+
+func TestGroupSingleFlight(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 3)
+	key := "hot-key"
+	owner := findNode(nodes, nodes[0].pool.Owner(key))
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, node := range nodes {
+			wg.Add(1)
+			go func(node *testNode) {
+				defer wg.Done()
+				if _, err := node.group.Get(ctx, key); err != nil {
+					t.Error(err)
+				}
+			}(node)
+		}
+	}
+	wg.Wait()
+	if loads := atomic.LoadInt32(&owner.loads); loads != 1 {
+		t.Fatalf("owner loads=%d, want 1", loads)
+	}
+}
+
+func TestGroupHotCache(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 2, WithHotCache[string](10, time.Minute, 2))
+	key := "k"
+	var remote *testNode
+	for _, node := range nodes {
+		if node.pool.Owner(key) != node.server.URL {
+			remote = node
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := remote.group.Get(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := remote.group.Stats()
+	if stats.PeerLoads != 2 || stats.HotHits != 3 {
+		t.Fatalf("stats=%+v, want 2 peer loads and 3 hot hits", stats)
+	}
+}
+
+func TestGroupPeerDown(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 2)
+	key := "k"
+	var remote, owner *testNode
+	for _, node := range nodes {
+		if node.pool.Owner(key) == node.server.URL {
+			owner = node
+		} else {
+			remote = node
+		}
+	}
+	owner.server.Close()
+	val, err := remote.group.Get(ctx, key)
+	if err != nil || *val != "value-of-k" {
+		t.Fatalf("Get=%v, %v", val, err)
+	}
+	if stats := remote.group.Stats(); stats.PeerErrors != 1 || stats.LocalLoads != 1 {
+		t.Fatalf("stats=%+v", stats)
+	}
+}
@@ -0,0 +1,182 @@
+package group
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myron934/go-viktor/cache"
+)
+
+const (
+	defaultBasePath = "/_group/"
+	defaultReplicas = 50
+	defaultTimeout  = time.Second * 5
+)
+
+// localGroup HTTPPool 处理其他节点的请求时使用的接口
+type localGroup interface {
+	getLocalEncoded(ctx context.Context, key string) ([]byte, error)
+}
+
+// HTTPPool 一组通过HTTP互相访问的节点
+// 每个节点根据一致性哈希负责一部分key, 不属于自己的key转发给负责的节点; 同时作为 http.Handler 响应其他节点的请求
+// 请求路径为 basePath + 分组名 + "/" + key, 分组名和key都经过 url.PathEscape
+type HTTPPool struct {
+	self     string
+	basePath string
+	replicas int
+	client   *http.Client
+	mutex    sync.RWMutex
+	ring     *Ring
+	groups   map[string]localGroup
+}
+
+type PoolOption func(pool *HTTPPool)
+
+// WithBasePath 指定请求路径的前缀, 默认为 "/_group/"
+func WithBasePath(basePath string) PoolOption {
+	return func(pool *HTTPPool) {
+		if !strings.HasSuffix(basePath, "/") {
+			basePath += "/"
+		}
+		pool.basePath = basePath
+	}
+}
+
+// WithReplicas 指定一致性哈希中每个节点的虚拟节点数量, 默认为50
+func WithReplicas(replicas int) PoolOption {
+	return func(pool *HTTPPool) {
+		pool.replicas = replicas
+	}
+}
+
+// WithHTTPClient 指定请求其他节点使用的client
+func WithHTTPClient(client *http.Client) PoolOption {
+	return func(pool *HTTPPool) {
+		pool.client = client
+	}
+}
+
+// NewHTTPPool 新建节点池, self 为本节点的地址, 如 "http://10.0.0.1:8080", 需要与 Set 中使用的地址一致
+func NewHTTPPool(self string, opts ...PoolOption) *HTTPPool {
+	pool := &HTTPPool{
+		self:     self,
+		basePath: defaultBasePath,
+		replicas: defaultReplicas,
+		client:   &http.Client{Timeout: defaultTimeout},
+		groups:   make(map[string]localGroup),
+	}
+	for _, opt := range opts {
+		opt(pool)
+	}
+	pool.ring = NewRing(pool.replicas, nil)
+	return pool
+}
+
+// Self 本节点的地址
+func (p *HTTPPool) Self() string {
+	return p.self
+}
+
+// Set 替换全部节点, 应包含本节点. 未设置任何节点时所有key都由本节点负责
+func (p *HTTPPool) Set(peers ...string) {
+	ring := NewRing(p.replicas, nil)
+	ring.Add(peers...)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ring = ring
+}
+
+// Owner 获取负责key的节点地址
+func (p *HTTPPool) Owner(key string) string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.ring.IsEmpty() {
+		return p.self
+	}
+	return p.ring.Get(key)
+}
+
+// ServeHTTP 响应其他节点的请求
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, p.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.SplitN(path[len(p.basePath):], "/", 2)
+	if len(parts) != 2 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	name, err := url.PathUnescape(parts[0])
+	if err != nil {
+		http.Error(w, "bad group name", http.StatusBadRequest)
+		return
+	}
+	key, err := url.PathUnescape(parts[1])
+	if err != nil {
+		http.Error(w, "bad key", http.StatusBadRequest)
+		return
+	}
+	p.mutex.RLock()
+	group, ok := p.groups[name]
+	p.mutex.RUnlock()
+	if !ok {
+		http.Error(w, "no such group: "+name, http.StatusBadRequest)
+		return
+	}
+	data, err := group.getLocalEncoded(r.Context(), key)
+	if err == cache.ErrorKeyNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+// register 注册分组, 分组名不能重复
+func (p *HTTPPool) register(name string, group localGroup) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.groups[name]; ok {
+		panic("duplicate group name " + name)
+	}
+	p.groups[name] = group
+}
+
+// fetch 从peer获取key序列化后的值, peer返回404时返回 cache.ErrorKeyNotFound
+func (p *HTTPPool) fetch(ctx context.Context, peer, group, key string) ([]byte, error) {
+	u := peer + p.basePath + url.PathEscape(group) + "/" + url.PathEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, cache.ErrorKeyNotFound
+	default:
+		return nil, fmt.Errorf("peer %s returned %d: %s", peer, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	hotKeySketchDepth = 4
+	hotKeySketchWidth = 1 << 10
+	hotKeyBuckets     = 6 // 滑动窗口分成的桶数, 窗口每次滑动 window/hotKeyBuckets
+)
+
+// HotKey 滑动窗口内访问次数最多的key
+type HotKey struct {
+	Key   string `json:"key"`   // 编码后的key
+	Count int64  `json:"count"` // 窗口内估计的访问次数, count-min sketch 只会高估不会低估
+}
+
+// countMinSketch 固定大小的计数草图, 估计值为各行计数的最小值
+type countMinSketch struct {
+	rows [hotKeySketchDepth][hotKeySketchWidth]uint32
+}
+
+func (s *countMinSketch) add(h1, h2 uint32) {
+	for i := range s.rows {
+		idx := (h1 + uint32(i)*h2) & (hotKeySketchWidth - 1)
+		if s.rows[i][idx] < ^uint32(0) {
+			s.rows[i][idx]++
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(h1, h2 uint32) uint32 {
+	min := ^uint32(0)
+	for i := range s.rows {
+		if n := s.rows[i][(h1+uint32(i)*h2)&(hotKeySketchWidth-1)]; n < min {
+			min = n
+		}
+	}
+	return min
+}
+
+func (s *countMinSketch) reset() {
+	*s = countMinSketch{}
+}
+
+// hotKeyTracker 用按时间分桶的 count-min sketch 统计滑动窗口内的访问次数,
+// 并维护一个容量有限的候选集合(heavy hitters), 新key的估计值超过候选集合中的最小值时替换它
+type hotKeyTracker struct {
+	mutex      sync.Mutex
+	buckets    [hotKeyBuckets]countMinSketch
+	bucketSpan time.Duration
+	current    int64 // 当前桶对应的时间片序号
+	candidates map[string]int64
+	maxSize    int
+	minCount   int64 // 候选集合中的最小估计值, 候选集合满了以后才有意义
+}
+
+func newHotKeyTracker(topK int, window time.Duration) *hotKeyTracker {
+	span := window / hotKeyBuckets
+	if span <= 0 {
+		span = 1
+	}
+	return &hotKeyTracker{
+		bucketSpan: span,
+		candidates: make(map[string]int64),
+		maxSize:    topK * 2,
+	}
+}
+
+func hashKey(strKey string) (uint32, uint32) {
+	h := fnv.New64a()
+	h.Write([]byte(strKey))
+	sum := h.Sum64()
+	// 第二个哈希为奇数, 保证各行的下标不同
+	return uint32(sum), uint32(sum>>32) | 1
+}
+
+// advance 滑动到now所在的时间片, 清空过期的桶
+func (t *hotKeyTracker) advance(now time.Time) {
+	slot := now.UnixNano() / int64(t.bucketSpan)
+	if slot <= t.current {
+		return
+	}
+	steps := slot - t.current
+	if steps > hotKeyBuckets {
+		steps = hotKeyBuckets
+	}
+	for i := int64(1); i <= steps; i++ {
+		t.buckets[(t.current+i)%hotKeyBuckets].reset()
+	}
+	t.current = slot
+	t.refreshCandidates()
+}
+
+func (t *hotKeyTracker) estimate(h1, h2 uint32) int64 {
+	var total int64
+	for i := range t.buckets {
+		total += int64(t.buckets[i].estimate(h1, h2))
+	}
+	return total
+}
+
+// refreshCandidates 窗口滑动后重新估计候选key, 删除窗口内没有访问的key
+func (t *hotKeyTracker) refreshCandidates() {
+	t.minCount = 0
+	first := true
+	for key := range t.candidates {
+		count := t.estimate(hashKey(key))
+		if count == 0 {
+			delete(t.candidates, key)
+			continue
+		}
+		t.candidates[key] = count
+		if first || count < t.minCount {
+			t.minCount, first = count, false
+		}
+	}
+}
+
+func (t *hotKeyTracker) record(strKey string, now time.Time) {
+	h1, h2 := hashKey(strKey)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.advance(now)
+	t.buckets[t.current%hotKeyBuckets].add(h1, h2)
+	count := t.estimate(h1, h2)
+	if _, ok := t.candidates[strKey]; ok || len(t.candidates) < t.maxSize {
+		t.candidates[strKey] = count
+		return
+	}
+	if count <= t.minCount {
+		return
+	}
+	// 替换估计值最小的候选key
+	var minKey string
+	minCount := int64(-1)
+	for key, n := range t.candidates {
+		if minCount < 0 || n < minCount {
+			minKey, minCount = key, n
+		}
+	}
+	delete(t.candidates, minKey)
+	t.candidates[strKey] = count
+	t.minCount = count
+	for _, n := range t.candidates {
+		if n < t.minCount {
+			t.minCount = n
+		}
+	}
+}
+
+// top 窗口内访问次数最多的n个key, 按次数倒序排列
+func (t *hotKeyTracker) top(n int, now time.Time) []HotKey {
+	t.mutex.Lock()
+	t.advance(now)
+	keys := make([]HotKey, 0, len(t.candidates))
+	for key, count := range t.candidates {
+		keys = append(keys, HotKey{Key: key, Count: count})
+	}
+	t.mutex.Unlock()
+	if n <= 0 {
+		return keys[:0]
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if n < len(keys) {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// HotKeys 滑动窗口内访问次数最多的n个key, n不超过 WithHotKeys 的topK, 未开启时返回nil
+// 可以用来发现导致锁竞争的热点key, 决定哪些数据需要复制到本地
+func (c *LoadingCache[K, V]) HotKeys(n int) []HotKey {
+	if c.hotKeys == nil {
+		return nil
+	}
+	if n > c.conf.hotKeys {
+		n = c.conf.hotKeys
+	}
+	return c.hotKeys.top(n, c.now())
+}
+
+func (c *LoadingCache[K, V]) recordAccess(key K) {
+	if c.hotKeys != nil {
+		c.hotKeys.record(c.stringKey(key), c.now())
+	}
+}
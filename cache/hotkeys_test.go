@@ -0,0 +1,56 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+	"github.com/myron934/go-viktor/cache"
+	"github.com/myron934/go-viktor/cache/cachetest"
+)
+
+func TestHotKeys(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	c := cache.NewLoadingCache(
+		cache.WithClock[string, int](clock),
+		cache.WithHotKeys[string, int](2, time.Minute),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			return viktor.Ptr(1), nil
+		}),
+	)
+	for i := 0; i < 10; i++ {
+		c.Get(ctx, "a")
+	}
+	for i := 0; i < 5; i++ {
+		c.Get(ctx, "b")
+	}
+	// 大量只访问一次的key不会挤掉热点key
+	for i := 0; i < 1000; i++ {
+		c.Get(ctx, fmt.Sprint("cold", i))
+	}
+	keys := c.HotKeys(5)
+	if len(keys) != 2 || keys[0].Key != "a" || keys[0].Count < 10 || keys[1].Key != "b" || keys[1].Count < 5 {
+		t.Fatalf("unexpected hot keys %+v", keys)
+	}
+
+	registry := cache.NewRegistry()
+	registry.Register("users", c)
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest("GET", "/caches/users/hot-keys?n=1", nil))
+	var got []cache.HotKey
+	json.NewDecoder(rec.Body).Decode(&got)
+	if len(got) != 1 || got[0].Key != "a" {
+		t.Fatalf("unexpected response %s", rec.Body)
+	}
+
+	// 滑出窗口以后不再是热点
+	clock.Advance(time.Minute * 2)
+	if keys = c.HotKeys(5); len(keys) != 0 {
+		t.Fatalf("hot keys should expire with the window: %+v", keys)
+	}
+}
@@ -0,0 +1,71 @@
+package httpcache
+
+import "time"
+
+type Config struct {
+	capacity    int           // 最多缓存的响应数量
+	defaultTTL  time.Duration // 响应没有 Cache-Control / Expires 时的缓存时间, 为0时不缓存
+	maxTTL      time.Duration // 缓存时间上限, 为0时不限制
+	maxBodySize int           // 超过该大小的响应不缓存
+	vary        []string      // 参与缓存key计算的请求头
+}
+
+type Option func(conf *Config) *Config
+
+func NewDefaultConf() *Config {
+	return &Config{
+		capacity:    1000,
+		maxBodySize: 1 << 20,
+	}
+}
+
+func WithCapacity(capacity int) Option {
+	if capacity < 0 {
+		panic("capacity less than 0")
+	}
+	return func(conf *Config) *Config {
+		conf.capacity = capacity
+		return conf
+	}
+}
+
+// WithDefaultTTL 响应没有 Cache-Control 和 Expires 时的缓存时间, 默认为0, 即不缓存
+func WithDefaultTTL(ttl time.Duration) Option {
+	if ttl < 0 {
+		panic("ttl less than 0")
+	}
+	return func(conf *Config) *Config {
+		conf.defaultTTL = ttl
+		return conf
+	}
+}
+
+// WithMaxTTL 缓存时间上限, 默认不限制
+func WithMaxTTL(ttl time.Duration) Option {
+	if ttl < 0 {
+		panic("ttl less than 0")
+	}
+	return func(conf *Config) *Config {
+		conf.maxTTL = ttl
+		return conf
+	}
+}
+
+// WithMaxBodySize 超过该大小的响应不缓存, 默认1MB
+func WithMaxBodySize(size int) Option {
+	if size < 0 {
+		panic("size less than 0")
+	}
+	return func(conf *Config) *Config {
+		conf.maxBodySize = size
+		return conf
+	}
+}
+
+// WithVary 指定参与缓存key计算的请求头, 这些请求头不同的请求分别缓存
+func WithVary(headers ...string) Option {
+	return func(conf *Config) *Config {
+		conf.vary = headers
+		return conf
+	}
+}
@@ -0,0 +1,277 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/myron934/go-viktor/cache"
+)
+
+const (
+	headerXCache = "X-Cache"
+	cacheHit     = "HIT"
+	cacheMiss    = "MISS"
+)
+
+// Response 缓存的响应
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	authorized bool // 请求带有 Authorization
+}
+
+// Middleware 缓存幂等GET请求响应的 net/http 中间件
+// 以 method + URL + WithVary 指定的请求头作为缓存key, 根据响应的 Cache-Control / Expires 决定缓存时间,
+// 支持 ETag / If-None-Match 条件请求, 同一个key的并发未命中只会调用一次下游handler, 响应不可缓存时其余请求各自调用handler
+type Middleware struct {
+	conf  *Config
+	cache *cache.LoadingCache[string, Response]
+}
+
+// New 新建中间件
+func New(opts ...Option) *Middleware {
+	m := &Middleware{conf: NewDefaultConf()}
+	for _, opt := range opts {
+		m.conf = opt(m.conf)
+	}
+	m.cache = cache.NewLoadingCache[string, Response](
+		cache.WithCapacity[string, Response](m.conf.capacity),
+		cache.WithExpireFunc[string, Response](func(_ context.Context, resp *Response) time.Duration {
+			return m.ttl(resp, time.Now())
+		}),
+	)
+	return m
+}
+
+// Cache 底层的缓存, 可以用来删除缓存的响应, key 通过 Key 计算
+func (m *Middleware) Cache() *cache.LoadingCache[string, Response] {
+	return m.cache
+}
+
+// Key 计算请求的缓存key
+func (m *Middleware) Key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.Host)
+	b.WriteString(r.URL.RequestURI())
+	for _, name := range m.conf.vary {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(name))
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// Handler 包装next, 只缓存GET请求, 请求带有 Cache-Control: no-store 时跳过缓存
+// 请求带有 Cache-Control: no-cache 或 max-age=0 时不使用缓存的响应, 调用handler并用新的响应更新缓存,
+// 请求的其他 max-age 取值以及 max-stale, min-fresh 被忽略.
+// 带有 Authorization 的请求, 只有响应带有 public 或 s-maxage 时才会缓存, 也才会共享给合并的请求
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || hasDirective(r.Header, "no-store") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := m.Key(r)
+		if maxAge, ok := directiveSeconds(r.Header, "max-age"); hasDirective(r.Header, "no-cache") || (ok && maxAge == 0) {
+			resp := record(next, r)
+			if m.ttl(resp, time.Now()) > 0 {
+				m.cache.Put(r.Context(), key, resp)
+			}
+			serve(w, r, resp, cacheMiss)
+			return
+		}
+		_, cached := m.cache.Peek(r.Context(), key)
+		loaded := false
+		resp, err := m.cache.GetOrCompute(r.Context(), key, func(string) (*Response, error) {
+			loaded = true
+			return record(next, r), nil
+		})
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch {
+		case loaded:
+			serve(w, r, resp, cacheMiss)
+		case cached:
+			serve(w, r, resp, cacheHit)
+		case m.ttl(resp, time.Now()) <= 0:
+			// 合并到了其他请求的加载, 但响应不可缓存(如 Set-Cookie, private, 已经过去的 Expires), 不能共享给其他用户, 自己调用handler
+			serve(w, r, record(next, r), cacheMiss)
+		default:
+			serve(w, r, resp, cacheMiss)
+		}
+	})
+}
+
+// ttl 根据响应计算缓存时间, 返回值不大于0表示不缓存
+func (m *Middleware) ttl(resp *Response, now time.Time) time.Duration {
+	if !cacheableStatus(resp.Status) || len(resp.Body) > m.conf.maxBodySize || len(resp.Header.Values("Set-Cookie")) > 0 {
+		return 0
+	}
+	if hasDirective(resp.Header, "no-store") || hasDirective(resp.Header, "no-cache") || hasDirective(resp.Header, "private") {
+		return 0
+	}
+	if resp.authorized && !hasDirective(resp.Header, "public") && !hasDirective(resp.Header, "s-maxage") {
+		return 0
+	}
+	ttl, ok := directiveSeconds(resp.Header, "s-maxage")
+	if !ok {
+		ttl, ok = directiveSeconds(resp.Header, "max-age")
+	}
+	if !ok {
+		if expires := resp.Header.Get("Expires"); expires != "" {
+			t, err := http.ParseTime(expires)
+			if err != nil {
+				return 0
+			}
+			if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+				now = date
+			}
+			ttl, ok = t.Sub(now), true
+		}
+	}
+	if !ok {
+		ttl = m.conf.defaultTTL
+	}
+	if m.conf.maxTTL > 0 && ttl > m.conf.maxTTL {
+		ttl = m.conf.maxTTL
+	}
+	return ttl
+}
+
+// record 调用next并记录响应, 200响应没有ETag时根据body生成
+func record(next http.Handler, r *http.Request) *Response {
+	rec := &recorder{header: make(http.Header)}
+	next.ServeHTTP(rec, r)
+	if !rec.wroteHeader {
+		rec.status = http.StatusOK
+	}
+	resp := &Response{
+		Status:     rec.status,
+		Header:     rec.header,
+		Body:       rec.body.Bytes(),
+		authorized: r.Header.Get("Authorization") != "",
+	}
+	if resp.Status == http.StatusOK && resp.Header.Get("ETag") == "" {
+		sum := sha1.Sum(resp.Body)
+		resp.Header.Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	}
+	return resp
+}
+
+// serve 输出缓存的响应, If-None-Match 与 ETag 匹配时返回304
+func serve(w http.ResponseWriter, r *http.Request, resp *Response, status string) {
+	header := w.Header()
+	for name, values := range resp.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(headerXCache, status)
+	etag := resp.Header.Get("ETag")
+	if resp.Status == http.StatusOK && etag != "" && etagMatch(r.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// etagMatch If-None-Match 使用弱比较, 忽略 W/ 前缀
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheableStatus RFC 7231 中默认可以缓存的状态码
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+func cacheControl(header http.Header) []string {
+	var directives []string
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if directive = strings.TrimSpace(directive); directive != "" {
+				directives = append(directives, strings.ToLower(directive))
+			}
+		}
+	}
+	return directives
+}
+
+func hasDirective(header http.Header, name string) bool {
+	for _, directive := range cacheControl(header) {
+		if directive == name || strings.HasPrefix(directive, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func directiveSeconds(header http.Header, name string) (time.Duration, bool) {
+	for _, directive := range cacheControl(header) {
+		if !strings.HasPrefix(directive, name+"=") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.Trim(directive[len(name)+1:], `"`))
+		if err != nil || seconds < 0 {
+			return 0, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
+
+// recorder 记录下游handler的响应
+type recorder struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.body.Write(data)
+}
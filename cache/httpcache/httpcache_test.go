@@ -0,0 +1,239 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/myron934/go-viktor/cache"
+)
+
+func do(t *testing.T, handler http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareCacheControl(t *testing.T) {
+	var calls int32
+	handler := New().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/short":
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+		fmt.Fprintf(w, "%s-%d", r.URL.Path, n)
+	}))
+
+	first := do(t, handler, "/public", nil)
+	second := do(t, handler, "/public", nil)
+	if first.Body.String() != second.Body.String() || second.Header().Get(headerXCache) != cacheHit {
+		t.Fatalf("public response not cached: %q, %q", first.Body.String(), second.Body.String())
+	}
+	if first.Header().Get(headerXCache) != cacheMiss {
+		t.Fatalf("first request X-Cache=%s", first.Header().Get(headerXCache))
+	}
+	for _, path := range []string{"/private", "/short", "/none"} {
+		a := do(t, handler, path, nil)
+		b := do(t, handler, path, nil)
+		if a.Body.String() == b.Body.String() {
+			t.Errorf("%s should not be cached", path)
+		}
+	}
+	// 请求要求 no-store 时跳过缓存
+	if rec := do(t, handler, "/public", http.Header{"Cache-Control": {"no-store"}}); rec.Header().Get(headerXCache) != "" {
+		t.Fatal("no-store request should bypass cache")
+	}
+}
+
+func TestMiddlewareVaryAndDefaultTTL(t *testing.T) {
+	m := New(WithVary("Accept-Language"), WithDefaultTTL(time.Minute))
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Accept-Language"))
+	}))
+	en := do(t, handler, "/greet", http.Header{"Accept-Language": {"en"}})
+	zh := do(t, handler, "/greet", http.Header{"Accept-Language": {"zh"}})
+	if en.Body.String() != "en" || zh.Body.String() != "zh" {
+		t.Fatalf("vary header ignored: en=%q, zh=%q", en.Body.String(), zh.Body.String())
+	}
+	if rec := do(t, handler, "/greet", http.Header{"Accept-Language": {"zh"}}); rec.Header().Get(headerXCache) != cacheHit {
+		t.Fatal("default ttl should cache response")
+	}
+}
+
+func TestMiddlewareConditional(t *testing.T) {
+	handler := New(WithDefaultTTL(time.Minute)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	first := do(t, handler, "/", nil)
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("etag not generated")
+	}
+	rec := do(t, handler, "/", http.Header{"If-None-Match": {`"other", ` + etag}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("code=%d, body=%q, want 304", rec.Code, rec.Body.String())
+	}
+	if rec = do(t, handler, "/", http.Header{"If-None-Match": {`"other"`}}); rec.Code != http.StatusOK {
+		t.Fatalf("code=%d, want 200", rec.Code)
+	}
+}
+
+// missGate 缓存记录到n次未命中后关闭返回的channel, handler等待它可以保证并发的请求都已经未命中
+func missGate(t *testing.T, m *Middleware, n int) <-chan struct{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	events := m.Cache().Events(ctx, func(event cache.Event[string, Response]) bool {
+		return event.Type == cache.EventMiss
+	})
+	gate := make(chan struct{})
+	go func() {
+		for i := 0; i < n; i++ {
+			<-events
+		}
+		close(gate)
+	}()
+	return gate
+}
+
+func TestMiddlewareCoalesce(t *testing.T) {
+	var calls int32
+	m := New(WithDefaultTTL(time.Minute))
+	gate := missGate(t, m, 10)
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-gate
+		io.WriteString(w, "slow")
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := do(t, handler, "/slow", nil)
+			if rec.Body.String() != "slow" {
+				t.Errorf("body=%q", rec.Body.String())
+			}
+			// 合并到同一次加载的请求也是未命中
+			if status := rec.Header().Get(headerXCache); status != cacheMiss {
+				t.Errorf("X-Cache=%s, want MISS", status)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("handler calls=%d, want 1", calls)
+	}
+	if status := do(t, handler, "/slow", nil).Header().Get(headerXCache); status != cacheHit {
+		t.Fatalf("X-Cache=%s, want HIT", status)
+	}
+}
+
+func TestMiddlewareCoalescePrivate(t *testing.T) {
+	var calls int32
+	m := New(WithDefaultTTL(time.Minute))
+	gate := missGate(t, m, 10)
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		<-gate
+		w.Header().Set("Set-Cookie", fmt.Sprintf("session=%d", n))
+		fmt.Fprint(w, n)
+	}))
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	bodies := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := do(t, handler, "/me", nil)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if bodies[rec.Body.String()] {
+				t.Errorf("response %s shared between requests", rec.Body.String())
+			}
+			bodies[rec.Body.String()] = true
+		}()
+	}
+	wg.Wait()
+	if calls != 10 {
+		t.Fatalf("handler calls=%d, want 10", calls)
+	}
+}
+
+func TestMiddlewareAuthorization(t *testing.T) {
+	var calls int32
+	handler := New(WithDefaultTTL(time.Minute)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/public" {
+			w.Header().Set("Cache-Control", "public")
+		}
+		fmt.Fprintf(w, "%s-%d", r.Header.Get("Authorization"), n)
+	}))
+	auth := http.Header{"Authorization": {"Bearer alice"}}
+	do(t, handler, "/me", auth)
+	if rec := do(t, handler, "/me", nil); rec.Header().Get(headerXCache) != cacheMiss || rec.Body.String() != "-2" {
+		t.Fatalf("authorized response shared: X-Cache=%s, body=%q", rec.Header().Get(headerXCache), rec.Body.String())
+	}
+	do(t, handler, "/public", auth)
+	if rec := do(t, handler, "/public", nil); rec.Header().Get(headerXCache) != cacheHit {
+		t.Fatal("public authorized response should be cached")
+	}
+}
+
+func TestMiddlewareRequestNoCache(t *testing.T) {
+	var calls int32
+	handler := New(WithDefaultTTL(time.Minute)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, atomic.AddInt32(&calls, 1))
+	}))
+	do(t, handler, "/", nil)
+	for i, header := range []http.Header{{"Cache-Control": {"no-cache"}}, {"Cache-Control": {"max-age=0"}}} {
+		rec := do(t, handler, "/", header)
+		if rec.Header().Get(headerXCache) != cacheMiss || rec.Body.String() != fmt.Sprint(i+2) {
+			t.Fatalf("%v: X-Cache=%s, body=%q", header, rec.Header().Get(headerXCache), rec.Body.String())
+		}
+	}
+	// 重新验证得到的响应更新了缓存
+	if rec := do(t, handler, "/", nil); rec.Header().Get(headerXCache) != cacheHit || rec.Body.String() != "3" {
+		t.Fatalf("X-Cache=%s, body=%q", rec.Header().Get(headerXCache), rec.Body.String())
+	}
+}
+
+func TestMiddlewareExpiredCoalesce(t *testing.T) {
+	var calls int32
+	m := New()
+	gate := missGate(t, m, 5)
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		<-gate
+		w.Header().Set("Expires", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		fmt.Fprint(w, n)
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			do(t, handler, "/old", nil)
+		}()
+	}
+	wg.Wait()
+	// 已经过期的响应不共享给合并的请求
+	if calls != 5 {
+		t.Fatalf("handler calls=%d, want 5", calls)
+	}
+}
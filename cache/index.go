@@ -0,0 +1,226 @@
+package cache
+
+import "time"
+
+// PutOption 写入元素时的附加选项
+type PutOption func(opts *putOptions)
+
+type putOptions struct {
+	tags        []string
+	hasTags     bool
+	pinned      bool
+	hasPinned   bool
+	priority    int
+	hasPriority bool
+	loaded      bool          // 是否通过加载写入, 加载的数据不需要写回, 见 WithWriteBehind
+	loadCost    time.Duration // 加载耗时, 只用于 LoadingCache 的提前刷新
+}
+
+var (
+	// Pinned 固定元素, 容量不足时不会被淘汰, 但仍然占用容量. 固定的元素数量超过 WithMaxPinnedRatio 的限制时按普通元素写入,
+	// 不返回错误, 开启 WithRecordStats 时计入 Stats.PinRejections
+	// 对已存在的元素, 不指定 Pinned / Unpinned 时保持原有的状态
+	Pinned PutOption = func(opts *putOptions) {
+		opts.pinned = true
+		opts.hasPinned = true
+	}
+	// Unpinned 取消固定
+	Unpinned PutOption = func(opts *putOptions) {
+		opts.pinned = false
+		opts.hasPinned = true
+	}
+)
+
+// Priority 设置元素的优先级, 默认为0, 容量不足时优先淘汰优先级低的元素, 同一优先级内按缓存自身的策略淘汰
+// 对已存在的元素, 不指定时保持原有的优先级
+func Priority(priority int) PutOption {
+	return func(opts *putOptions) {
+		opts.priority = priority
+		opts.hasPriority = true
+	}
+}
+
+// Tags 为元素打上标签, 之后可以通过 InvalidateTag 批量删除
+// 对已存在的元素会替换其原有的标签
+func Tags(tags ...string) PutOption {
+	return func(opts *putOptions) {
+		opts.tags = tags
+		opts.hasTags = true
+	}
+}
+
+// fromLoad 标记为通过加载写入, 并记录加载耗时
+func fromLoad(cost time.Duration) PutOption {
+	return func(opts *putOptions) {
+		opts.loaded = true
+		opts.loadCost = cost
+	}
+}
+
+func newPutOptions(opts []PutOption) *putOptions {
+	options := &putOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// keyIndex 缓存key的二级索引, 用于按标签或前缀批量删除元素, 删除的开销与受影响的元素数量成正比
+// 索引中的key都是经过 stringKey 编码后的字符串, 非并发安全, 由所属的缓存加锁保护
+type keyIndex struct {
+	tags      map[string]map[string]struct{} // 标签 -> key集合
+	entryTags map[string][]string            // key -> 标签
+	prefix    *trieNode                      // 前缀树, 未开启前缀索引时为nil
+}
+
+func newKeyIndex(prefixIndex bool) *keyIndex {
+	idx := &keyIndex{
+		tags:      make(map[string]map[string]struct{}),
+		entryTags: make(map[string][]string),
+	}
+	if prefixIndex {
+		idx.prefix = &trieNode{}
+	}
+	return idx
+}
+
+// add 新增key
+func (idx *keyIndex) add(strKey string) {
+	if idx.prefix != nil {
+		idx.prefix.insert(strKey)
+	}
+}
+
+// setTags 替换key的标签
+func (idx *keyIndex) setTags(strKey string, tags []string) {
+	idx.removeTags(strKey)
+	if len(tags) == 0 {
+		return
+	}
+	own := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys, ok := idx.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			idx.tags[tag] = keys
+		}
+		if _, dup := keys[strKey]; dup {
+			continue
+		}
+		keys[strKey] = struct{}{}
+		own = append(own, tag)
+	}
+	idx.entryTags[strKey] = own
+}
+
+// remove 删除key及其标签
+func (idx *keyIndex) remove(strKey string) {
+	idx.removeTags(strKey)
+	if idx.prefix != nil {
+		idx.prefix.delete(strKey)
+	}
+}
+
+func (idx *keyIndex) removeTags(strKey string) {
+	for _, tag := range idx.entryTags[strKey] {
+		keys := idx.tags[tag]
+		delete(keys, strKey)
+		if len(keys) == 0 {
+			delete(idx.tags, tag)
+		}
+	}
+	delete(idx.entryTags, strKey)
+}
+
+// keysWithTag 获取打了tag标签的全部key
+func (idx *keyIndex) keysWithTag(tag string) []string {
+	keys := make([]string, 0, len(idx.tags[tag]))
+	for strKey := range idx.tags[tag] {
+		keys = append(keys, strKey)
+	}
+	return keys
+}
+
+// keysWithPrefix 获取以prefix开头的全部key, 未开启前缀索引时返回false
+func (idx *keyIndex) keysWithPrefix(prefix string) ([]string, bool) {
+	if idx.prefix == nil {
+		return nil, false
+	}
+	return idx.prefix.collect(prefix), true
+}
+
+// clear 清空索引
+func (idx *keyIndex) clear() {
+	idx.tags = make(map[string]map[string]struct{})
+	idx.entryTags = make(map[string][]string)
+	if idx.prefix != nil {
+		idx.prefix = &trieNode{}
+	}
+}
+
+// trieNode 按字节划分的前缀树
+type trieNode struct {
+	children map[byte]*trieNode
+	terminal bool
+}
+
+func (node *trieNode) insert(key string) {
+	for i := 0; i < len(key); i++ {
+		if node.children == nil {
+			node.children = make(map[byte]*trieNode)
+		}
+		child, ok := node.children[key[i]]
+		if !ok {
+			child = &trieNode{}
+			node.children[key[i]] = child
+		}
+		node = child
+	}
+	node.terminal = true
+}
+
+func (node *trieNode) delete(key string) {
+	path := make([]*trieNode, 0, len(key)+1)
+	path = append(path, node)
+	for i := 0; i < len(key); i++ {
+		child, ok := node.children[key[i]]
+		if !ok {
+			return
+		}
+		node = child
+		path = append(path, node)
+	}
+	node.terminal = false
+	// 自底向上删除不再需要的节点
+	for i := len(key); i > 0; i-- {
+		if path[i].terminal || len(path[i].children) > 0 {
+			return
+		}
+		delete(path[i-1].children, key[i-1])
+	}
+}
+
+func (node *trieNode) collect(prefix string) []string {
+	for i := 0; i < len(prefix); i++ {
+		child, ok := node.children[prefix[i]]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	var keys []string
+	buf := []byte(prefix)
+	var walk func(n *trieNode)
+	walk = func(n *trieNode) {
+		if n.terminal {
+			keys = append(keys, string(buf))
+		}
+		for b, child := range n.children {
+			buf = append(buf, b)
+			walk(child)
+			buf = buf[:len(buf)-1]
+		}
+	}
+	walk(node)
+	return keys
+}
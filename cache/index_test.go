@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	viktor "github.com/myron934/go-viktor"
+)
+
+func TestTrieNode(t *testing.T) {
+	root := &trieNode{}
+	for _, key := range []string{"user:1", "user:1:profile", "user:12", "order:1"} {
+		root.insert(key)
+	}
+	keys := root.collect("user:1")
+	sort.Strings(keys)
+	if fmt.Sprint(keys) != "[user:1 user:12 user:1:profile]" {
+		t.Fatalf("collect=%v", keys)
+	}
+	root.delete("user:1:profile")
+	root.delete("user:12")
+	if keys = root.collect("user:1"); fmt.Sprint(keys) != "[user:1]" {
+		t.Fatalf("collect after delete=%v", keys)
+	}
+	root.delete("user:1")
+	if _, ok := root.children['u']; ok {
+		t.Fatal("empty branch should be pruned")
+	}
+}
+
+func TestLRUCacheInvalidateTag(t *testing.T) {
+	cache := NewLRUCache(WithCapacity[string, int](10))
+	cache.PutWithOptions("user:1:profile", viktor.Ptr(1), Tags("user:1"))
+	cache.PutWithOptions("user:1:orders", viktor.Ptr(2), Tags("user:1", "orders"))
+	cache.PutWithOptions("user:2:orders", viktor.Ptr(3), Tags("user:2", "orders"))
+	cache.Put("other", viktor.Ptr(4))
+
+	if n := cache.InvalidateTag("user:1"); n != 2 {
+		t.Fatalf("InvalidateTag removed %d, want 2", n)
+	}
+	if cache.Contains("user:1:profile") || cache.Contains("user:1:orders") || !cache.Contains("user:2:orders") {
+		t.Fatalf("unexpected keys after InvalidateTag: %v", cache.Keys())
+	}
+	// user:1:orders 删除后不应该再出现在 orders 标签中
+	if n := cache.InvalidateTag("orders"); n != 1 {
+		t.Fatalf("InvalidateTag removed %d, want 1", n)
+	}
+	// 重新打标签会替换旧标签
+	cache.PutWithOptions("other", viktor.Ptr(5), Tags("a"))
+	cache.PutWithOptions("other", viktor.Ptr(6), Tags("b"))
+	if n := cache.InvalidateTag("a"); n != 0 {
+		t.Fatalf("InvalidateTag removed %d, want 0", n)
+	}
+	if n := cache.InvalidateTag("b"); n != 1 || cache.Size() != 0 {
+		t.Fatalf("InvalidateTag removed %d, size=%d", n, cache.Size())
+	}
+}
+
+func TestLRUCacheInvalidatePrefix(t *testing.T) {
+	for _, indexed := range []bool{true, false} {
+		opts := []Option[string, int]{WithCapacity[string, int](3)}
+		if indexed {
+			opts = append(opts, WithPrefixIndex[string, int]())
+		}
+		cache := NewLRUCache(opts...)
+		cache.Put("user:1:profile", viktor.Ptr(1))
+		cache.Put("user:1:orders", viktor.Ptr(2))
+		cache.Put("user:2:orders", viktor.Ptr(3))
+		// 淘汰 user:1:profile
+		cache.Put("user:10:orders", viktor.Ptr(4))
+
+		if n := cache.InvalidatePrefix("user:1:"); n != 1 {
+			t.Fatalf("indexed=%v, InvalidatePrefix removed %d, want 1", indexed, n)
+		}
+		if keys := cache.Keys(); fmt.Sprint(keys) != "[user:10:orders user:2:orders]" {
+			t.Fatalf("indexed=%v, keys=%v", indexed, keys)
+		}
+	}
+}
+
+func TestLoadingCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := NewLoadingCache[string, int](WithPrefixIndex[string, int]())
+	c.PutWithOptions(ctx, "user:1:profile", viktor.Ptr(1), Tags("user:1"))
+	c.Put(ctx, "user:1:orders", viktor.Ptr(2))
+	c.Put(ctx, "user:2:orders", viktor.Ptr(3))
+	if n, _ := c.InvalidateTag(ctx, "user:1"); n != 1 {
+		t.Fatalf("InvalidateTag removed %d, want 1", n)
+	}
+	if n, _ := c.InvalidatePrefix(ctx, "user:1:"); n != 1 {
+		t.Fatalf("InvalidatePrefix removed %d, want 1", n)
+	}
+	c.Remove(ctx, "user:2:orders")
+	if c.Size() != 0 {
+		t.Fatalf("size=%d, want 0", c.Size())
+	}
+}
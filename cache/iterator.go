@@ -0,0 +1,58 @@
+package cache
+
+// Iterator 缓存快照迭代器
+// 创建时复制了缓存中的全部元素, 之后其他协程对缓存的修改不会影响迭代结果, 迭代过程中也可以安全地访问缓存
+type Iterator[K, V any] struct {
+	entries []Entry[K, V]
+	index   int
+}
+
+func newIterator[K, V any](entries []Entry[K, V]) *Iterator[K, V] {
+	return &Iterator[K, V]{
+		entries: entries,
+		index:   -1,
+	}
+}
+
+// Next 移动到下一个元素, 没有更多元素时返回false
+func (it *Iterator[K, V]) Next() bool {
+	if it.index+1 >= len(it.entries) {
+		it.index = len(it.entries)
+		return false
+	}
+	it.index++
+	return true
+}
+
+// Key 当前元素的key
+func (it *Iterator[K, V]) Key() K {
+	return it.entries[it.index].key
+}
+
+// Value 当前元素的值
+func (it *Iterator[K, V]) Value() *V {
+	return it.entries[it.index].value
+}
+
+// Len 快照中的元素数量
+func (it *Iterator[K, V]) Len() int {
+	return len(it.entries)
+}
+
+// Keys 快照中的全部key, 顺序与迭代顺序一致
+func (it *Iterator[K, V]) Keys() []K {
+	keys := make([]K, 0, len(it.entries))
+	for _, entry := range it.entries {
+		keys = append(keys, entry.key)
+	}
+	return keys
+}
+
+// Range 按迭代顺序依次调用fn, fn返回false时停止
+func (it *Iterator[K, V]) Range(fn func(key K, value *V) bool) {
+	for it.Next() {
+		if !fn(it.Key(), it.Value()) {
+			return
+		}
+	}
+}
@@ -3,16 +3,20 @@ package cache
 import (
 	"container/heap"
 	"fmt"
+	"sort"
 	"sync"
 )
 
 // LFUCache (Least Recently Used，最近最少使用) 淘汰策略缓存
 type LFUCache[V any] struct {
-	capacity    int
-	cache       map[string]*LFUItem
-	pq          PriorityQueue
-	mutex       sync.Mutex
-	keyToString func(key any) string
+	cache   map[string]*LFUItem
+	pq      PriorityQueue
+	mutex   sync.Mutex
+	conf    *Config[any, V]
+	stats   *statsCounter
+	pinned  int               // 固定的元素数量
+	pending []removal[any, V] // 等待回调的删除通知, 见 unlock
+	events  *eventHub[any, V]
 }
 
 type LFUItem struct {
@@ -20,6 +24,8 @@ type LFUItem struct {
 	value     any
 	frequency int
 	index     int
+	pinned    bool
+	priority  int
 }
 
 // NewLFUCache 新建lfu缓存(并发安全)
@@ -34,15 +40,30 @@ func NewLFUCache[V any](capacity int) *LFUCache[V] {
 // customKeyFunc key转化成字符串的方法
 // // V 为value类型
 func NewLFUCacheWithCustomKey[V any](capacity int, customKeyFunc func(key any) string) *LFUCache[V] {
-	if capacity < 0 {
-		panic("capacity less than 0")
+	return NewLFUCacheWithOptions[V](
+		WithCapacity[any, V](capacity),
+		WithKeyEncoder[any, V](customKeyFunc),
+	)
+}
+
+// NewLFUCacheWithOptions 新建lfu缓存(并发安全), 通过 Option 指定容量, key的编码方式等配置
+// V 为value类型
+func NewLFUCacheWithOptions[V any](opts ...Option[any, V]) *LFUCache[V] {
+	c := &LFUCache[V]{
+		cache: make(map[string]*LFUItem),
+		pq:    make(PriorityQueue, 0),
+		conf:  NewDefaultConf[any, V](),
+	}
+	for _, opt := range opts {
+		c.conf = opt(c.conf)
 	}
-	return &LFUCache[V]{
-		capacity:    capacity,
-		cache:       make(map[string]*LFUItem),
-		pq:          make(PriorityQueue, 0),
-		keyToString: customKeyFunc,
+	if c.conf.clock == nil {
+		// WithConfig 传入的配置可能没有设置时钟
+		c.conf.clock = SystemClock
 	}
+	c.stats = newStatsCounter(c.conf.recordStats)
+	c.events = &eventHub[any, V]{}
+	return c
 }
 
 // Get 获取数据
@@ -50,54 +71,265 @@ func NewLFUCacheWithCustomKey[V any](capacity int, customKeyFunc func(key any) s
 // 以及实现了 String() string 接口的类
 func (lfu *LFUCache[V]) Get(key any) *V {
 	lfu.mutex.Lock()
-	defer lfu.mutex.Unlock()
+	defer lfu.unlock()
 
 	keyStr := lfu.stringKey(key)
 	if item, ok := lfu.cache[keyStr]; ok {
 		lfu.updateFrequency(item)
+		lfu.stats.recordHit()
+		lfu.emit(EventHit, key, item.value.(*V))
 		return item.value.(*V)
 	}
+	lfu.stats.recordMiss()
+	lfu.emit(EventMiss, key, nil)
 	return nil
 }
 
+// Peek 获取数据, 但不增加访问频率
+func (lfu *LFUCache[V]) Peek(key any) (*V, bool) {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	item, ok := lfu.cache[lfu.stringKey(key)]
+	if !ok {
+		return nil, false
+	}
+	return item.value.(*V), true
+}
+
+// Contains 判断key是否存在, 不增加访问频率
+func (lfu *LFUCache[V]) Contains(key any) bool {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	_, ok := lfu.cache[lfu.stringKey(key)]
+	return ok
+}
+
+// Keys 获取全部key, 按访问频率从高到低排序, 不增加访问频率
+func (lfu *LFUCache[V]) Keys() []any {
+	return lfu.Iterator().Keys()
+}
+
+// Range 按访问频率从高到低遍历元素, fn返回false时停止遍历
+// 遍历的是调用时的快照, 不增加访问频率, fn中可以安全地访问缓存
+func (lfu *LFUCache[V]) Range(fn func(key any, value *V) bool) {
+	lfu.Iterator().Range(fn)
+}
+
+// Iterator 获取当前缓存的快照迭代器, 按访问频率从高到低排序
+func (lfu *LFUCache[V]) Iterator() *Iterator[any, V] {
+	lfu.mutex.Lock()
+	entries := make([]Entry[any, V], 0, len(lfu.pq))
+	frequencies := make([]int, 0, len(lfu.pq))
+	for _, item := range lfu.pq {
+		entries = append(entries, Entry[any, V]{key: item.key, value: item.value.(*V)})
+		frequencies = append(frequencies, item.frequency)
+	}
+	lfu.mutex.Unlock()
+
+	sort.Stable(byFrequency[V]{entries: entries, frequencies: frequencies})
+	return newIterator(entries)
+}
+
 // Put 设置缓存数据
 // Key可以支持基础类型: string | int | int8 | int16 | int32 | int64 | float32 | float64 | uint8 | uint16 | uint32 | uint64 | bool
 // 以及实现了 String() string 接口的类
 func (lfu *LFUCache[V]) Put(key any, value *V) {
-	if lfu.capacity == 0 {
-		return
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	lfu.put(lfu.stringKey(key), key, value, nil)
+}
+
+// PutWithOptions 设置缓存数据, 并指定附加选项, 支持 Pinned, Unpinned, Priority, 不支持 Tags
+func (lfu *LFUCache[V]) PutWithOptions(key any, value *V, opts ...PutOption) {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	lfu.put(lfu.stringKey(key), key, value, newPutOptions(opts))
+}
+
+// PutIfAbsent key不存在时写入value
+// 返回key当前对应的值, 以及key是否已经存在
+func (lfu *LFUCache[V]) PutIfAbsent(key any, value *V) (*V, bool) {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	strKey := lfu.stringKey(key)
+	if item, ok := lfu.cache[strKey]; ok {
+		lfu.updateFrequency(item)
+		return item.value.(*V), true
 	}
+	lfu.put(strKey, key, value, nil)
+	return value, false
+}
+
+// GetOrCompute key存在时直接返回, 否则调用fn计算并写入缓存
+// fn在锁内执行, 同一个缓存同一时刻只会有一个fn在运行, fn返回error时不写入缓存
+func (lfu *LFUCache[V]) GetOrCompute(key any, fn func(key any) (*V, error)) (*V, error) {
 	lfu.mutex.Lock()
-	defer lfu.mutex.Unlock()
+	defer lfu.unlock()
+
 	strKey := lfu.stringKey(key)
+	if item, ok := lfu.cache[strKey]; ok {
+		lfu.updateFrequency(item)
+		return item.value.(*V), nil
+	}
+	value, err := fn(key)
+	if err != nil {
+		return nil, err
+	}
+	lfu.put(strKey, key, value, nil)
+	return value, nil
+}
+
+// Compute 原子地根据旧值计算新值
+// remapping的参数为旧值以及key是否存在, 返回新值以及是否保留; 不保留时删除key
+// 返回计算后的值, 以及key是否仍然存在
+func (lfu *LFUCache[V]) Compute(key any, remapping func(old *V, ok bool) (*V, bool)) (*V, bool) {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	strKey := lfu.stringKey(key)
+	var old *V
+	item, ok := lfu.cache[strKey]
+	if ok {
+		old = item.value.(*V)
+	}
+	return lfu.applyCompute(strKey, key, old, ok, remapping)
+}
+
+// ComputeIfPresent key存在时原子地根据旧值计算新值, 不存在时不调用remapping
+// 返回计算后的值, 以及key是否仍然存在
+func (lfu *LFUCache[V]) ComputeIfPresent(key any, remapping func(old *V) (*V, bool)) (*V, bool) {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	strKey := lfu.stringKey(key)
+	item, ok := lfu.cache[strKey]
+	if !ok {
+		return nil, false
+	}
+	return lfu.applyCompute(strKey, key, item.value.(*V), true, func(old *V, _ bool) (*V, bool) {
+		return remapping(old)
+	})
+}
+
+// CompareAndSwap 当key存在且当前值与old是同一个指针时, 替换为new
+// 返回是否替换成功
+func (lfu *LFUCache[V]) CompareAndSwap(key any, old, new *V) bool {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	item, ok := lfu.cache[lfu.stringKey(key)]
+	if !ok || item.value.(*V) != old {
+		return false
+	}
+	lfu.replace(item, new)
+	lfu.updateFrequency(item)
+	return true
+}
+
+func (lfu *LFUCache[V]) applyCompute(strKey string, key any, old *V, ok bool, remapping func(old *V, ok bool) (*V, bool)) (*V, bool) {
+	value, keep := remapping(old, ok)
+	if !keep {
+		if ok {
+			lfu.remove(strKey, RemovalExplicit)
+		}
+		return nil, false
+	}
+	lfu.put(strKey, key, value, nil)
+	if _, exist := lfu.cache[strKey]; !exist {
+		// capacity为0时不会写入
+		return nil, false
+	}
+	return value, true
+}
+
+// put 写入元素, options可以为nil, 调用方需持有锁
+func (lfu *LFUCache[V]) put(strKey string, key any, value *V, options *putOptions) {
+	if lfu.conf.capacity == 0 {
+		return
+	}
 
 	if item, ok := lfu.cache[strKey]; ok {
-		item.value = value
+		lfu.emit(EventPut, key, value)
+		lfu.replace(item, value)
+		lfu.setPlacement(item, options)
 		lfu.updateFrequency(item)
 		return
 	}
 
-	if len(lfu.cache) >= lfu.capacity {
-		// Remove the least frequently used item
-		lfu.deleteLeastUsed()
+	if len(lfu.cache) >= lfu.conf.capacity && !lfu.deleteLeastUsed() {
+		// 全部元素都被固定, 无法写入
+		return
 	}
 
+	lfu.emit(EventPut, key, value)
 	newItem := &LFUItem{
 		key:       key,
 		value:     value,
 		frequency: 1,
 	}
+	lfu.setPlacement(newItem, options)
 	heap.Push(&lfu.pq, newItem)
 	lfu.cache[strKey] = newItem
 }
 
+// setPlacement 根据options修改元素的固定状态和优先级, 调用方需持有锁并在之后调整堆
+func (lfu *LFUCache[V]) setPlacement(item *LFUItem, options *putOptions) {
+	if options == nil {
+		return
+	}
+	if options.hasPinned && options.pinned != item.pinned {
+		if !options.pinned {
+			item.pinned = false
+			lfu.pinned--
+		} else if lfu.pinned < lfu.conf.maxPinned() {
+			item.pinned = true
+			lfu.pinned++
+		} else {
+			lfu.stats.recordPinRejection()
+		}
+	}
+	if options.hasPriority {
+		item.priority = options.priority
+	}
+}
+
+// Size 获取当前元素数量
+func (lfu *LFUCache[V]) Size() int {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	return len(lfu.cache)
+}
+
+// Capacity 获取最大容量
+func (lfu *LFUCache[V]) Capacity() int {
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+
+	return lfu.conf.capacity
+}
+
+// Stats 获取统计数据, 需要通过 WithRecordStats 开启
+func (lfu *LFUCache[V]) Stats() Stats {
+	return lfu.stats.snapshot()
+}
+
 // Clear 清空缓存
 func (lfu *LFUCache[V]) Clear() {
 	lfu.mutex.Lock()
-	defer lfu.mutex.Unlock()
+	defer lfu.unlock()
 
+	for _, item := range lfu.pq {
+		lfu.notifyRemoval(item, RemovalExplicit)
+	}
 	lfu.cache = make(map[string]*LFUItem)
 	lfu.pq = make(PriorityQueue, 0)
+	lfu.pinned = 0
 }
 
 // Resize 重设缓存大小
@@ -106,27 +338,59 @@ func (lfu *LFUCache[V]) Resize(capacity int) {
 		panic("capacity less than 0")
 	}
 	lfu.mutex.Lock()
-	defer lfu.mutex.Unlock()
+	defer lfu.unlock()
 
-	for len(lfu.cache) >= capacity {
-		lfu.deleteLeastUsed()
+	// 固定的元素不会被淘汰, 缩容后元素数量可能仍然超过容量
+	for len(lfu.cache) > capacity {
+		if !lfu.deleteLeastUsed() {
+			break
+		}
 	}
-	lfu.capacity = capacity
+	lfu.conf.capacity = capacity
 }
 
 func (lfu *LFUCache[V]) Print() {
 	lfu.mutex.Lock()
-	defer lfu.mutex.Unlock()
-	printf("capacity=%v\n", lfu.capacity)
+	defer lfu.unlock()
+	printf("capacity=%v\n", lfu.conf.capacity)
 	for key, item := range lfu.cache {
 		printf("key=%v, val=%v, frequency=%v, index=%v\n", key, item.value, item.frequency, item.index)
 	}
 }
 
-// deleteLeastUsed 删除优先级最低的一个元素
-func (lfu *LFUCache[V]) deleteLeastUsed() {
+// remove 删除元素, 调用方需持有锁
+func (lfu *LFUCache[V]) remove(strKey string, cause RemovalCause) {
+	item, ok := lfu.cache[strKey]
+	if !ok {
+		return
+	}
+	heap.Remove(&lfu.pq, item.index)
+	delete(lfu.cache, strKey)
+	if item.pinned {
+		lfu.pinned--
+	}
+	lfu.notifyRemoval(item, cause)
+}
+
+// replace 替换元素的值, 值不同时通知旧值被替换. 调用方需持有锁
+func (lfu *LFUCache[V]) replace(item *LFUItem, value *V) {
+	if item.value.(*V) != value {
+		lfu.notifyRemoval(item, RemovalReplaced)
+		item.value = value
+	}
+}
+
+// deleteLeastUsed 淘汰一个元素: 未固定的元素中优先级最低的, 访问频率最低的元素. 没有可以淘汰的元素时返回false
+func (lfu *LFUCache[V]) deleteLeastUsed() bool {
+	// 固定的元素排在堆的最后, 堆顶被固定说明全部元素都被固定
+	if len(lfu.pq) == 0 || lfu.pq[0].pinned {
+		return false
+	}
 	removedItem := heap.Pop(&lfu.pq).(*LFUItem)
 	delete(lfu.cache, lfu.stringKey(removedItem.key))
+	lfu.stats.recordEviction()
+	lfu.notifyRemoval(removedItem, RemovalEvicted)
+	return true
 }
 
 func (lfu *LFUCache[V]) updateFrequency(item *LFUItem) {
@@ -144,10 +408,25 @@ func (lfu *LFUCache[V]) stringKey(key any) string {
 	case int, int8, int16, int32, int64, float32, float64, uint8, uint16, uint32, uint64, bool:
 		return fmt.Sprint(key)
 	}
-	if lfu.keyToString == nil {
+	if lfu.conf.keyToString == nil {
 		panic("unknown key type and key to string function is nil")
 	}
-	return lfu.keyToString(key)
+	return lfu.conf.keyToString(key)
+}
+
+// byFrequency 将快照按访问频率从高到低排序
+type byFrequency[V any] struct {
+	entries     []Entry[any, V]
+	frequencies []int
+}
+
+func (b byFrequency[V]) Len() int { return len(b.entries) }
+
+func (b byFrequency[V]) Less(i, j int) bool { return b.frequencies[i] > b.frequencies[j] }
+
+func (b byFrequency[V]) Swap(i, j int) {
+	b.entries[i], b.entries[j] = b.entries[j], b.entries[i]
+	b.frequencies[i], b.frequencies[j] = b.frequencies[j], b.frequencies[i]
 }
 
 // PriorityQueue implements heap.Interface and holds Items.
@@ -156,6 +435,13 @@ type PriorityQueue []*LFUItem
 func (pq *PriorityQueue) Len() int { return len((*pq)) }
 
 func (pq *PriorityQueue) Less(i, j int) bool {
+	if (*pq)[i].pinned != (*pq)[j].pinned {
+		// 固定的元素排在最后
+		return !(*pq)[i].pinned
+	}
+	if (*pq)[i].priority != (*pq)[j].priority {
+		return (*pq)[i].priority < (*pq)[j].priority
+	}
 	if (*pq)[i].frequency == (*pq)[j].frequency {
 		// If frequencies are the same, prioritize by index
 		return (*pq)[i].index < (*pq)[j].index
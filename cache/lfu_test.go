@@ -1,9 +1,8 @@
 package cache
 
 import (
-	"math/rand"
+	"fmt"
 	"testing"
-	"time"
 
 	viktor "github.com/myron934/go-viktor"
 )
@@ -26,37 +25,77 @@ func TestNewLFUCache(t *testing.T) {
 	cache.Print()
 }
 
-func TestParallelRunLFU(t *testing.T) {
-	cache := NewLFUCache[int](20)
-	go func() {
-		for {
-			time.Sleep(time.Millisecond * 10)
-			key := rand.Intn(50)
-			cache.Get(key)
-			printf("get key=%d, val=%v\n", key, cache.Get(key))
-		}
-	}()
-	go func() {
-		for {
-			time.Sleep(time.Millisecond * 10)
-			key := rand.Intn(50)
-			cache.Put(key, &key)
-			printf("set key=%d, val=%d\n", key, key)
-		}
-	}()
-	go func() {
-		for {
-			time.Sleep(time.Millisecond * 10)
-			c := rand.Intn(10) + 10
-			cache.Resize(c)
-			printf("reset capacity=%d\n", c)
-		}
-	}()
-	go func() {
-		for {
-			time.Sleep(time.Millisecond * 10)
-			cache.Print()
+func TestLFUCacheCompute(t *testing.T) {
+	cache := NewLFUCache[int](10)
+	if val, loaded := cache.PutIfAbsent("a", viktor.Ptr(1)); loaded || *val != 1 {
+		t.Fatalf("PutIfAbsent on absent key: val=%v, loaded=%v", *val, loaded)
+	}
+	if val, loaded := cache.PutIfAbsent("a", viktor.Ptr(2)); !loaded || *val != 1 {
+		t.Fatalf("PutIfAbsent on present key: val=%v, loaded=%v", *val, loaded)
+	}
+	if val, err := cache.GetOrCompute("b", func(key any) (*int, error) { return viktor.Ptr(10), nil }); err != nil || *val != 10 {
+		t.Fatalf("GetOrCompute: val=%v, err=%v", val, err)
+	}
+	if val, ok := cache.ComputeIfPresent("b", func(old *int) (*int, bool) { return viktor.Ptr(*old + 1), true }); !ok || *val != 11 {
+		t.Fatalf("ComputeIfPresent: val=%v, ok=%v", val, ok)
+	}
+	if _, ok := cache.Compute("b", func(old *int, ok bool) (*int, bool) { return nil, false }); ok || cache.Get("b") != nil {
+		t.Fatal("Compute returning keep=false should remove key")
+	}
+	old := cache.Get("a")
+	if !cache.CompareAndSwap("a", old, viktor.Ptr(3)) || *cache.Get("a") != 3 {
+		t.Fatal("CompareAndSwap failed")
+	}
+}
+
+func TestLFUCachePeekAndRange(t *testing.T) {
+	cache := NewLFUCache[int](3)
+	cache.Put("a", viktor.Ptr(1))
+	cache.Put("b", viktor.Ptr(2))
+	cache.Put("c", viktor.Ptr(3))
+	cache.Get("b")
+	cache.Get("b")
+	cache.Get("c")
+
+	for i := 0; i < 5; i++ {
+		if val, ok := cache.Peek("a"); !ok || *val != 1 {
+			t.Fatalf("Peek: val=%v, ok=%v", val, ok)
 		}
-	}()
-	time.Sleep(time.Second * 3600)
+	}
+	if !cache.Contains("a") || cache.Contains("d") {
+		t.Fatal("Contains returned wrong result")
+	}
+	if keys := cache.Keys(); fmt.Sprint(keys) != "[b c a]" {
+		t.Fatalf("Keys=%v, want [b c a]", keys)
+	}
+	cache.Put("d", viktor.Ptr(4))
+	if cache.Contains("a") {
+		t.Fatal("Peek should not affect frequency")
+	}
+}
+
+func TestLFUPinnedAndPriority(t *testing.T) {
+	lfu := NewLFUCache[int](3)
+	lfu.PutWithOptions("config", viktor.Ptr(0), Pinned)
+	lfu.PutWithOptions("important", viktor.Ptr(1), Priority(1))
+	lfu.Put("a", viktor.Ptr(2))
+	lfu.Get("a")
+	lfu.Get("a")
+	// a访问频率最高, 但优先级最低
+	lfu.Put("b", viktor.Ptr(3))
+	if lfu.Contains("a") || !lfu.Contains("config") || !lfu.Contains("important") {
+		t.Fatalf("keys = %v, want a evicted", lfu.Keys())
+	}
+	lfu.PutWithOptions("b", viktor.Ptr(3), Pinned)
+	lfu.PutWithOptions("important", viktor.Ptr(1), Pinned)
+	// 全部元素都被固定时无法写入
+	lfu.Put("c", viktor.Ptr(4))
+	if lfu.Contains("c") || lfu.Size() != 3 {
+		t.Fatalf("keys = %v, want c rejected", lfu.Keys())
+	}
+	lfu.PutWithOptions("b", viktor.Ptr(3), Unpinned)
+	lfu.Put("c", viktor.Ptr(4))
+	if lfu.Contains("b") || !lfu.Contains("c") {
+		t.Fatalf("keys = %v, want b evicted", lfu.Keys())
+	}
 }
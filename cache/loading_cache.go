@@ -3,8 +3,14 @@ package cache
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/myron934/go-viktor/concurrency"
 )
 
 type (
@@ -23,8 +29,11 @@ type ICache[K, V any] interface {
 }
 
 type LoadingItem[V any] struct {
-	expire time.Time
-	value  *V
+	expire     time.Time
+	write      time.Time     // 写入时间, 用于 WithRefreshAfterWrite
+	loadCost   time.Duration // 加载耗时, 用于 WithEarlyRefresh, 通过Put写入的元素为0
+	value      *V
+	refreshing int32 // 是否正在后台刷新, 保证同一个元素同一时刻只有一个刷新任务
 }
 
 type LoadingCache[K, V any] struct {
@@ -32,6 +41,22 @@ type LoadingCache[K, V any] struct {
 	mutex         sync.Mutex
 	conf          *Config[K, V]
 	lastClearTime time.Time
+	flight        concurrency.SingleFlight[V]
+	bus           atomic.Value // *busAttachment
+	stats         *statsCounter
+	sweeper       Timer // 定时清理过期元素的定时器, 缓存为空时不启动
+	closed        bool
+	pending       []removal[K, V] // 等待回调的删除通知, 见 unlock
+	writeBehind   *writeBehind[K, V]
+	keyLocks      [keyLockStripes]sync.Mutex // 开启 WithWriter / WithDeleter 时串行化同一个key的写入和删除
+	hotKeys       *hotKeyTracker             // 开启 WithHotKeys 时统计访问最多的key
+	events        *eventHub[K, V]
+	loading       map[string]*loadTicket // 正在进行的加载, 见 load
+}
+
+// loadTicket 一次正在进行的加载, 加载期间key被写入或删除时标记为stale, 加载结果不再写入缓存
+type loadTicket struct {
+	stale bool
 }
 
 func NewLoadingCache[K, V any](opts ...Option[K, V]) *LoadingCache[K, V] {
@@ -42,21 +67,51 @@ func NewLoadingCache[K, V any](opts ...Option[K, V]) *LoadingCache[K, V] {
 	for _, opt := range opts {
 		c.conf = opt(c.conf)
 	}
-	c.lruCache = NewLRUCache[K, LoadingItem[V]](
+	if c.conf.clock == nil {
+		// WithConfig 传入的配置可能没有设置时钟
+		c.conf.clock = SystemClock
+	}
+	lruOpts := []Option[K, LoadingItem[V]]{
 		WithCapacity[K, LoadingItem[V]](c.conf.capacity),
 		WithKeyEncoder[K, LoadingItem[V]](c.conf.keyToString),
-	)
+		WithMaxPinnedRatio[K, LoadingItem[V]](c.conf.maxPinnedRatio),
+	}
+	if c.conf.prefixIndex {
+		lruOpts = append(lruOpts, WithPrefixIndex[K, LoadingItem[V]]())
+	}
+	// 淘汰和过期事件也通过内部lru的删除通知产生, 因此总是注册
+	lruOpts = append(lruOpts, WithRemovalListener[K, LoadingItem[V]](c.onInnerRemoval))
+	if c.conf.recordStats {
+		// 命中与加载由LoadingCache自己统计, 内部的lru只用于统计淘汰和未能固定的数量
+		lruOpts = append(lruOpts, WithRecordStats[K, LoadingItem[V]]())
+	}
+	c.lruCache = NewLRUCache[K, LoadingItem[V]](lruOpts...)
+	c.stats = newStatsCounter(c.conf.recordStats)
+	c.events = &eventHub[K, V]{}
+	if c.conf.writeBehind != nil {
+		if c.conf.writer != nil {
+			panic("write through and write behind can not be used together")
+		}
+		c.writeBehind = newWriteBehind[K, V](c.conf.writeBehind, c.conf.clock)
+	}
+	if c.conf.hotKeys > 0 {
+		c.hotKeys = newHotKeyTracker(c.conf.hotKeys, c.conf.hotKeyWindow)
+	}
 	return c
 }
 
+// Get 获取数据, 不存在或已过期时通过 getterFunc 加载
+// 加载在锁外进行, 同一个key的并发加载会被合并为一次
 func (c *LoadingCache[K, V]) Get(_ context.Context, key K) (*V, error) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	val, err := c.lruCache.Get(key)
-	now := time.Now()
-	if err == nil && val != nil && now.Before(val.expire) {
-		return val.value, nil
+	c.recordAccess(key)
+	if item, ok := c.getItem(key); ok {
+		c.stats.recordHit()
+		c.emit(EventHit, key, item.value, nil)
+		c.refreshIfStale(key, item)
+		return item.value, nil
 	}
+	c.stats.recordMiss()
+	c.emit(EventMiss, key, nil, nil)
 	if newVal, err := c.refresh(key); err == nil {
 		return newVal, nil
 	}
@@ -68,6 +123,45 @@ func (c *LoadingCache[K, V]) MustGet(ctx context.Context, key K) *V {
 	return val
 }
 
+// Peek 获取未过期的数据, 不触发加载, 也不改变元素的访问顺序
+func (c *LoadingCache[K, V]) Peek(_ context.Context, key K) (*V, bool) {
+	item, ok := c.lruCache.Peek(key)
+	if !ok || item == nil || !c.now().Before(item.expire) {
+		return nil, false
+	}
+	return item.value, true
+}
+
+// Contains 判断key是否存在且未过期, 不触发加载, 也不改变元素的访问顺序
+func (c *LoadingCache[K, V]) Contains(ctx context.Context, key K) bool {
+	_, ok := c.Peek(ctx, key)
+	return ok
+}
+
+// Keys 获取全部未过期的key, 按最近使用到最久未使用排序
+func (c *LoadingCache[K, V]) Keys() []K {
+	return c.Iterator().Keys()
+}
+
+// Range 按最近使用到最久未使用的顺序遍历未过期的元素, fn返回false时停止遍历
+// 遍历的是调用时的快照, 不改变元素的访问顺序, fn中可以安全地访问缓存
+func (c *LoadingCache[K, V]) Range(fn func(key K, value *V) bool) {
+	c.Iterator().Range(fn)
+}
+
+// Iterator 获取当前缓存中未过期元素的快照迭代器, 按最近使用到最久未使用排序
+func (c *LoadingCache[K, V]) Iterator() *Iterator[K, V] {
+	it := c.lruCache.Iterator()
+	now := c.now()
+	entries := make([]Entry[K, V], 0, it.Len())
+	for it.Next() {
+		if item := it.Value(); item != nil && now.Before(item.expire) {
+			entries = append(entries, Entry[K, V]{key: it.Key(), value: item.value})
+		}
+	}
+	return newIterator(entries)
+}
+
 func (c *LoadingCache[K, V]) Refresh(_ context.Context, key K) error {
 	_, err := c.refresh(key)
 	return err
@@ -77,49 +171,465 @@ func (c *LoadingCache[K, V]) refresh(key K) (*V, error) {
 	if c.conf.getterFunc == nil {
 		return nil, ErrorKeyNotFound
 	}
-	val, err := c.conf.getterFunc(key)
+	if c.conf.existenceFilter != nil && !c.conf.existenceFilter.TestString(c.stringKey(key)) {
+		return nil, ErrorKeyNotFound
+	}
+	return c.load(key, c.conf.getterFunc, false)
+}
+
+// load 通过fn加载key并写入缓存, 同一个key的并发加载会被合并为一次
+// recheck为true时先检查缓存, 其他调用方的加载刚刚写入的值不再重复加载
+func (c *LoadingCache[K, V]) load(key K, fn func(key K) (*V, error), recheck bool) (*V, error) {
+	strKey := c.stringKey(key)
+	val, err, _ := c.flight.Do(strKey, func() (*V, error) {
+		if recheck {
+			if item, ok := c.getItem(key); ok {
+				return item.value, nil
+			}
+		}
+		// 加载不持有key的段锁, 加载期间的写入和删除通过ticket作废加载结果, 避免新值被加载的旧值覆盖
+		ticket := &loadTicket{}
+		c.mutex.Lock()
+		if c.loading == nil {
+			c.loading = make(map[string]*loadTicket)
+		}
+		c.loading[strKey] = ticket
+		c.mutex.Unlock()
+
+		var cost time.Duration
+		val, ok := c.pendingWrite(key)
+		if ok {
+			// 还没有写回的数据比存储中的新
+			c.emit(EventLoad, key, val, nil)
+		} else {
+			start := c.now()
+			var err error
+			val, err = fn(key)
+			cost = c.now().Sub(start)
+			c.stats.recordLoad(cost, err)
+			c.emit(EventLoad, key, val, err)
+			if err != nil {
+				c.mutex.Lock()
+				delete(c.loading, strKey)
+				c.mutex.Unlock()
+				return nil, err
+			}
+		}
+		c.mutex.Lock()
+		defer c.unlock()
+		delete(c.loading, strKey)
+		if ticket.stale {
+			// 加载期间key被写入或删除, 加载的值可能已经过时, 不写入缓存
+			if item, ok := c.getItem(key); ok {
+				return item.value, nil
+			}
+			return val, nil
+		}
+		if err := c.put(key, val, fromLoad(cost)); err != nil {
+			return nil, err
+		}
+		return val, nil
+	})
+	return val, err
+}
+
+// supersede key被写入或删除, 正在进行的加载结果作废. 调用方需持有锁
+func (c *LoadingCache[K, V]) supersede(strKey string) {
+	if ticket, ok := c.loading[strKey]; ok {
+		ticket.stale = true
+	}
+}
+
+// discard key被删除, 作废正在进行的加载以及还没有写回的值. 调用方需持有锁
+func (c *LoadingCache[K, V]) discard(strKey string) {
+	c.supersede(strKey)
+	if c.writeBehind != nil {
+		c.writeBehind.forget(strKey)
+	}
+}
+
+// discardIf 对编码后的key满足match的全部key执行 discard. 调用方需持有锁
+func (c *LoadingCache[K, V]) discardIf(match func(strKey string) bool) {
+	for strKey, ticket := range c.loading {
+		if match(strKey) {
+			ticket.stale = true
+		}
+	}
+	if c.writeBehind != nil {
+		c.writeBehind.forgetIf(match)
+	}
+}
+
+// Put 设置缓存数据, 接入了失效消息总线时会通知其他副本删除该key
+func (c *LoadingCache[K, V]) Put(ctx context.Context, key K, val *V) error {
+	return c.PutWithOptions(ctx, key, val)
+}
+
+// PutWithOptions 设置缓存数据, 并指定附加选项, 如 Tags
+// 配置了 WithWriter 时先写入存储, 失败时不更新缓存
+func (c *LoadingCache[K, V]) PutWithOptions(ctx context.Context, key K, val *V, opts ...PutOption) error {
+	unlockKey := c.lockKey(key)
+	defer unlockKey()
+	if err := c.writeThrough(ctx, key, val); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	err := c.put(key, val, opts...)
+	c.unlock()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return c.broadcast(ctx, InvalidationKey, c.stringKey(key))
+}
+
+// Remove 删除元素
+// 配置了 WithDeleter 时逐个先从存储删除, 遇到错误时停止并返回该错误, 之前已经从存储删除的key仍然会从缓存删除
+func (c *LoadingCache[K, V]) Remove(ctx context.Context, keys ...K) error {
+	if c.conf.deleter != nil || c.conf.writer != nil {
+		return c.removeThrough(ctx, keys)
+	}
+	strKeys := make([]string, 0, len(keys))
+	c.mutex.Lock()
+	for _, key := range keys {
+		c.lruCache.Remove(key)
+		strKeys = append(strKeys, c.stringKey(key))
+		c.discard(c.stringKey(key))
 	}
-	if err = c.put(key, val); err != nil {
-		return nil, err
+	c.unlock()
+	return c.broadcast(ctx, InvalidationKey, strKeys...)
+}
+
+func (c *LoadingCache[K, V]) removeThrough(ctx context.Context, keys []K) error {
+	strKeys := make([]string, 0, len(keys))
+	var err error
+	for _, key := range keys {
+		unlockKey := c.lockKey(key)
+		c.mutex.Lock()
+		if err = c.deleteThroughLocked(ctx, key); err == nil {
+			c.lruCache.Remove(key)
+			strKeys = append(strKeys, c.stringKey(key))
+			c.discard(c.stringKey(key))
+		}
+		c.unlock()
+		unlockKey()
+		if err != nil {
+			break
+		}
+	}
+	if len(strKeys) > 0 {
+		if broadcastErr := c.broadcast(ctx, InvalidationKey, strKeys...); err == nil {
+			err = broadcastErr
+		}
 	}
-	return val, nil
+	return err
 }
 
-func (c *LoadingCache[K, V]) Put(_ context.Context, key K, val *V) error {
+// Clear 清空缓存
+func (c *LoadingCache[K, V]) Clear(ctx context.Context) error {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	return c.put(key, val)
+	c.lruCache.Clear()
+	c.discardIf(func(string) bool { return true })
+	c.unlock()
+	return c.broadcast(ctx, InvalidationAll)
 }
 
-func (c *LoadingCache[K, V]) put(key K, val *V) error {
+// InvalidateTag 删除打了tag标签的全部元素, 返回本地删除的数量
+func (c *LoadingCache[K, V]) InvalidateTag(ctx context.Context, tag string) (int, error) {
+	c.mutex.Lock()
+	keys := c.lruCache.invalidateTag(tag)
+	for _, strKey := range keys {
+		c.discard(strKey)
+	}
+	c.unlock()
+	return len(keys), c.broadcast(ctx, InvalidationTag, tag)
+}
+
+// InvalidatePrefix 删除编码后的key以prefix开头的全部元素, 返回本地删除的数量
+// 开启 WithPrefixIndex 时通过前缀索引查找, 否则需要遍历全部元素
+func (c *LoadingCache[K, V]) InvalidatePrefix(ctx context.Context, prefix string) (int, error) {
+	c.mutex.Lock()
+	n := c.lruCache.InvalidatePrefix(prefix)
+	c.discardIf(func(strKey string) bool { return strings.HasPrefix(strKey, prefix) })
+	c.unlock()
+	return n, c.broadcast(ctx, InvalidationPrefix, prefix)
+}
+
+func (c *LoadingCache[K, V]) put(key K, val *V, opts ...PutOption) error {
+	options := newPutOptions(opts)
+	if c.writeBehind != nil && !options.loaded {
+		c.writeBehind.markDirty(c.stringKey(key), key, val)
+	}
+	if !options.loaded {
+		c.supersede(c.stringKey(key))
+		c.emit(EventPut, key, val, nil)
+	}
+	ttl := c.conf.expireAfterWrite
+	if c.conf.expireFunc != nil {
+		if ttl = c.conf.expireFunc(context.Background(), val); ttl <= 0 {
+			c.lruCache.Remove(key)
+			return nil
+		}
+	}
+	if c.conf.expireJitter > 0 {
+		ttl += time.Duration(float64(ttl) * c.conf.expireJitter * (2*rand.Float64() - 1))
+	}
+	if c.conf.existenceFilter != nil {
+		c.conf.existenceFilter.AddString(c.stringKey(key))
+	}
+	now := c.now()
 	item := &LoadingItem[V]{
-		expire: time.Now().Add(c.conf.expireAfterWrite),
-		value:  val,
+		expire:   now.Add(ttl),
+		write:    now,
+		loadCost: options.loadCost,
+		value:    val,
 	}
 	//if c.lruCache.IsFull() {
 	//	c.clearExpireItem(false)
 	//}
-	c.lruCache.Put(key, item)
+	c.lruCache.PutWithOptions(key, item, opts...)
+	c.startSweeper()
 	return nil
 }
 
+// refreshIfStale 元素写入超过 refreshAfterWrite, 或者按XFetch算法需要提前刷新时在后台重新加载
+// 加载失败时保留旧值, 下次访问时重试
+func (c *LoadingCache[K, V]) refreshIfStale(key K, item *LoadingItem[V]) {
+	if c.conf.getterFunc == nil || !c.shouldRefresh(item) || !atomic.CompareAndSwapInt32(&item.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		if _, err := c.refresh(key); err != nil {
+			atomic.StoreInt32(&item.refreshing, 0)
+		}
+	}()
+}
+
+// PutIfAbsent key不存在(或已过期)时写入val
+// 返回key当前对应的值, 以及key是否已经存在
+func (c *LoadingCache[K, V]) PutIfAbsent(ctx context.Context, key K, val *V) (*V, bool, error) {
+	unlockKey := c.lockKey(key)
+	defer unlockKey()
+	c.mutex.Lock()
+	if item, ok := c.getItem(key); ok {
+		c.unlock()
+		return item.value, true, nil
+	}
+	err := c.writeThroughLocked(ctx, key, val)
+	if err == nil {
+		err = c.put(key, val)
+	}
+	c.unlock()
+	if err != nil {
+		return nil, false, err
+	}
+	return val, false, c.broadcast(ctx, InvalidationKey, c.stringKey(key))
+}
+
+// GetOrCompute key存在时直接返回, 否则调用fn计算并写入缓存, fn为nil时使用 getterFunc
+// 与Get的加载一样在锁外执行, 同一个key同一时刻只会有一个fn在运行, 并发的调用方共享其结果
+func (c *LoadingCache[K, V]) GetOrCompute(_ context.Context, key K, fn func(key K) (*V, error)) (*V, error) {
+	c.recordAccess(key)
+	if item, ok := c.getItem(key); ok {
+		c.stats.recordHit()
+		c.emit(EventHit, key, item.value, nil)
+		return item.value, nil
+	}
+	c.stats.recordMiss()
+	c.emit(EventMiss, key, nil, nil)
+	if fn == nil {
+		return c.refresh(key)
+	}
+	return c.load(key, fn, true)
+}
+
+// Compute 原子地根据旧值计算新值, 已过期的key视为不存在
+// remapping的参数为旧值以及key是否存在, 返回新值以及是否保留; 不保留时删除key
+// 返回计算后的值, 以及key是否仍然存在
+func (c *LoadingCache[K, V]) Compute(ctx context.Context, key K, remapping func(old *V, ok bool) (*V, bool)) (*V, bool, error) {
+	unlockKey := c.lockKey(key)
+	defer unlockKey()
+	c.mutex.Lock()
+	var old *V
+	item, ok := c.getItem(key)
+	if ok {
+		old = item.value
+	}
+	val, present, err := c.applyCompute(ctx, key, old, ok, remapping)
+	c.unlock()
+	if err != nil || (!ok && !present) {
+		return val, present, err
+	}
+	return val, present, c.broadcast(ctx, InvalidationKey, c.stringKey(key))
+}
+
+// ComputeIfPresent key存在(且未过期)时原子地根据旧值计算新值, 不存在时不调用remapping
+// 返回计算后的值, 以及key是否仍然存在
+func (c *LoadingCache[K, V]) ComputeIfPresent(ctx context.Context, key K, remapping func(old *V) (*V, bool)) (*V, bool, error) {
+	unlockKey := c.lockKey(key)
+	defer unlockKey()
+	c.mutex.Lock()
+	item, ok := c.getItem(key)
+	if !ok {
+		c.unlock()
+		return nil, false, nil
+	}
+	val, present, err := c.applyCompute(ctx, key, item.value, true, func(old *V, _ bool) (*V, bool) {
+		return remapping(old)
+	})
+	c.unlock()
+	if err != nil {
+		return nil, false, err
+	}
+	return val, present, c.broadcast(ctx, InvalidationKey, c.stringKey(key))
+}
+
+// CompareAndSwap 当key存在(且未过期), 并且当前值与old是同一个指针时, 替换为new并重置过期时间
+// 返回是否替换成功
+func (c *LoadingCache[K, V]) CompareAndSwap(ctx context.Context, key K, old, new *V) (bool, error) {
+	unlockKey := c.lockKey(key)
+	defer unlockKey()
+	c.mutex.Lock()
+	item, ok := c.getItem(key)
+	if !ok || item.value != old {
+		c.unlock()
+		return false, nil
+	}
+	err := c.writeThroughLocked(ctx, key, new)
+	if err == nil {
+		err = c.put(key, new)
+	}
+	c.unlock()
+	if err != nil {
+		return false, err
+	}
+	return true, c.broadcast(ctx, InvalidationKey, c.stringKey(key))
+}
+
+// applyCompute 调用方需持有key的段锁和 c.mutex, 写入或删除存储时会临时释放 c.mutex
+func (c *LoadingCache[K, V]) applyCompute(ctx context.Context, key K, old *V, ok bool, remapping func(old *V, ok bool) (*V, bool)) (*V, bool, error) {
+	val, keep := remapping(old, ok)
+	if !keep {
+		if ok {
+			if err := c.deleteThroughLocked(ctx, key); err != nil {
+				return old, true, err
+			}
+			c.lruCache.Remove(key)
+		}
+		c.discard(c.stringKey(key))
+		return nil, false, nil
+	}
+	if err := c.writeThroughLocked(ctx, key, val); err != nil {
+		return old, ok, err
+	}
+	if err := c.put(key, val); err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// getItem 获取未过期的元素
+func (c *LoadingCache[K, V]) getItem(key K) (*LoadingItem[V], bool) {
+	item, err := c.lruCache.Get(key)
+	if err != nil || item == nil || !c.now().Before(item.expire) {
+		return nil, false
+	}
+	return item, true
+}
+
 func (c *LoadingCache[K, V]) Size() int {
 	return c.lruCache.Size()
 }
 
-func (c *LoadingCache[K, V]) clearExpireItem(force bool) {
-	now := time.Now()
-	size := c.Size()
-	if !force && c.lastClearTime.Add(c.conf.minClearInterval).After(now) {
+// Capacity 获取最大容量
+func (c *LoadingCache[K, V]) Capacity() int {
+	return c.lruCache.Capacity()
+}
+
+// Resize 重设缓存大小, 超出的元素按最久未使用的顺序淘汰
+func (c *LoadingCache[K, V]) Resize(capacity int) {
+	c.mutex.Lock()
+	defer c.unlock()
+	c.lruCache.Resize(capacity)
+	c.conf.capacity = capacity
+}
+
+// Stats 获取统计数据, 需要通过 WithRecordStats 开启
+func (c *LoadingCache[K, V]) Stats() Stats {
+	stats := c.stats.snapshot()
+	inner := c.lruCache.Stats()
+	stats.Evictions = inner.Evictions
+	stats.PinRejections = inner.PinRejections
+	return stats
+}
+
+// Close 停止定时清理, 之后缓存仍然可以使用, 但过期的元素只会在访问或被淘汰时删除
+// 开启了 WithWriteBehind 时同时停止后台写回, 并写回全部剩余的脏数据, 写回失败或ctx结束时返回错误
+func (c *LoadingCache[K, V]) Close(ctx context.Context) error {
+	c.mutex.Lock()
+	c.closed = true
+	if c.sweeper != nil {
+		c.sweeper.Stop()
+		c.sweeper = nil
+	}
+	c.unlock()
+	if c.writeBehind != nil {
+		return c.writeBehind.close(ctx)
+	}
+	return nil
+}
+
+// startSweeper 启动定时清理, 已启动或未配置 clearInterval 时什么也不做. 调用方需持有锁
+func (c *LoadingCache[K, V]) startSweeper() {
+	if c.sweeper != nil || c.closed || c.conf.clearInterval <= 0 {
 		return
 	}
-	c.lruCache.RemoveIf(func(key K, val *LoadingItem[V]) bool {
-		return val.expire.Before(now)
-	})
+	c.sweeper = c.conf.clock.AfterFunc(c.conf.clearInterval, c.sweep)
+}
+
+// sweep 清理过期元素, 缓存不为空时等待下一次清理
+func (c *LoadingCache[K, V]) sweep() {
+	c.mutex.Lock()
+	defer c.unlock()
+	c.sweeper = nil
+	if c.closed {
+		return
+	}
+	c.clearExpireItem(true)
+	if c.lruCache.Size() > 0 {
+		c.startSweeper()
+	}
+}
+
+// clearExpireItem 删除已过期的元素, 返回删除的数量. 调用方需持有锁
+// force为false时, 距离上次清理不足 minClearInterval 则跳过
+func (c *LoadingCache[K, V]) clearExpireItem(force bool) int {
+	now := c.now()
+	if !force && c.lastClearTime.Add(c.conf.minClearInterval).After(now) {
+		return 0
+	}
+	size := c.lruCache.Size()
+	c.lruCache.removeIf(func(key K, val *LoadingItem[V]) bool {
+		return !now.Before(val.expire)
+	}, RemovalExpired)
 	c.lastClearTime = now
-	fmt.Printf("clear expire item, num: %d, cost: %d ms\n", size-c.Size(), time.Now().Sub(now).Milliseconds())
+	return size - c.lruCache.Size()
+}
+
+func (c *LoadingCache[K, V]) shouldRefresh(item *LoadingItem[V]) bool {
+	now := c.now()
+	if c.conf.refreshAfterWrite > 0 && now.Sub(item.write) >= c.conf.refreshAfterWrite {
+		return true
+	}
+	if c.conf.earlyRefreshBeta > 0 && item.loadCost > 0 {
+		// XFetch: now - loadCost*beta*ln(rand) >= expire, rand取(0,1]避免ln(0)
+		gap := -float64(item.loadCost) * c.conf.earlyRefreshBeta * math.Log(1-rand.Float64())
+		return !now.Add(time.Duration(gap)).Before(item.expire)
+	}
+	return false
+}
+
+func (c *LoadingCache[K, V]) now() time.Time {
+	return c.conf.clock.Now()
 }
 
 func (c *LoadingCache[K, V]) stringKey(key any) string {
@@ -1,58 +1,256 @@
-package cache
+package cache_test
 
 import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	viktor "github.com/myron934/go-viktor"
+	"github.com/myron934/go-viktor/cache"
+	"github.com/myron934/go-viktor/cache/cachetest"
 )
 
 func TestNewLoadingCache(t *testing.T) {
 	ctx := context.Background()
-	c := NewLoadingCache[string, int](WithCapacity[string, int](3))
-	printf("key=a, value=%v\n", c.MustGet(ctx, "a"))
+	c := cache.NewLoadingCache[string, int](cache.WithCapacity[string, int](3))
+	fmt.Printf("key=a, value=%v\n", c.MustGet(ctx, "a"))
 
-	c = NewLoadingCache[string, int](
-		WithCapacity[string, int](3),
-		WithKeyEncoder[string, int](func(k string) string { return k + "*" }),
-		WithGetterFunc[string, int](func(key string) (*int, error) {
+	c = cache.NewLoadingCache[string, int](
+		cache.WithCapacity[string, int](3),
+		cache.WithKeyEncoder[string, int](func(k string) string { return k + "*" }),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
 			println("refresh key " + key)
 			return viktor.Ptr(1), nil
 		}),
 	)
-	printf("key=a, value=%v\n", c.MustGet(ctx, "a"))
+	fmt.Printf("key=a, value=%v\n", c.MustGet(ctx, "a"))
 }
 
 func TestNewLoadingCache2(t *testing.T) {
-	c := NewLoadingCache[int, int](
-		WithCapacity[int, int](10000000),
-		WithExpireAfterWrite[int, int](time.Second*5),
-		WithKeyEncoder[int, int](func(k int) string { return fmt.Sprint(k) }),
-		WithGetterFunc[int, int](func(key int) (*int, error) {
-			//fmt.Printf("refresh key %d \n", key)
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	var loads int32
+	c := cache.NewLoadingCache[int, int](
+		cache.WithClock[int, int](clock),
+		cache.WithCapacity[int, int](1000),
+		cache.WithExpireAfterWrite[int, int](time.Second*5),
+		cache.WithRefreshAfterWrite[int, int](time.Second*3),
+		cache.WithClearInterval[int, int](time.Second),
+		cache.WithKeyEncoder[int, int](func(k int) string { return fmt.Sprint(k) }),
+		cache.WithGetterFunc[int, int](func(key int) (*int, error) {
+			atomic.AddInt32(&loads, 1)
 			return viktor.Ptr(key), nil
 		}),
 	)
-	for i := 0; i < 5; i++ {
-		go func() {
-			for {
-				now := time.Now()
-				c.MustGet(context.Background(), rand.Intn(10000000))
-				cost := time.Now().Sub(now)
-				if cost.Milliseconds() > 20 {
-					fmt.Printf("get cache, cost: %d ms\n", time.Now().Sub(now).Milliseconds())
+	defer c.Close(ctx)
 
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for j := 0; j < 1000; j++ {
+				key := r.Intn(100)
+				if val := c.MustGet(ctx, key); val == nil || *val != key {
+					t.Errorf("key %d got %v", key, val)
+					return
 				}
-				//printf("key=a, value=%v\n", c.MustGet(context.Background(), rand.Int()))
 			}
-		}()
+		}(int64(i))
+	}
+	wg.Wait()
+	// 时间没有推进, 每个key只加载一次
+	if n := c.Size(); n == 0 || n > 100 || int32(n) != atomic.LoadInt32(&loads) {
+		t.Fatalf("size=%d, loads=%d", n, atomic.LoadInt32(&loads))
+	}
+
+	// 到达刷新时间后先返回旧值, 后台重新加载, 刷新后过期时间重新计算
+	clock.Advance(time.Second * 3)
+	before := atomic.LoadInt32(&loads)
+	if val := c.MustGet(ctx, 0); val == nil || *val != 0 {
+		t.Fatalf("stale read %v", val)
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	for atomic.LoadInt32(&loads) == before {
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// 等待刷新结果写入缓存
+	for c.Size() == 0 || !c.Contains(ctx, 0) {
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed key missing")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 其他key在写入5秒后过期, 并被定时清理删除; 刷新过的key还没有过期
+	clock.Advance(time.Second * 2)
+	if c.Size() != 1 || !c.Contains(ctx, 0) || c.Contains(ctx, 1) {
+		t.Fatalf("after expiry size=%d, keys=%v", c.Size(), c.Keys())
+	}
+	clock.Advance(time.Second * 3)
+	if c.Size() != 0 || clock.Timers() != 0 {
+		t.Fatalf("size=%d, timers=%d after all keys expired", c.Size(), clock.Timers())
+	}
+}
+
+func TestLoadingCacheCompute(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	c := cache.NewLoadingCache[string, int](
+		cache.WithClock[string, int](clock),
+		cache.WithCapacity[string, int](10),
+		cache.WithExpireAfterWrite[string, int](time.Minute),
+	)
+	if _, loaded, _ := c.PutIfAbsent(ctx, "a", viktor.Ptr(1)); loaded {
+		t.Fatal("PutIfAbsent on absent key reported loaded")
+	}
+	if val, loaded, _ := c.PutIfAbsent(ctx, "a", viktor.Ptr(2)); !loaded || *val != 1 {
+		t.Fatalf("PutIfAbsent on present key: val=%v, loaded=%v", *val, loaded)
+	}
+	clock.Advance(time.Minute)
+	if _, ok, _ := c.ComputeIfPresent(ctx, "a", func(old *int) (*int, bool) { return old, true }); ok {
+		t.Fatal("expired key should be treated as absent")
+	}
+	for i := 0; i < 3; i++ {
+		c.Compute(ctx, "counter", func(old *int, ok bool) (*int, bool) {
+			if !ok {
+				return viktor.Ptr(1), true
+			}
+			return viktor.Ptr(*old + 1), true
+		})
+	}
+	if val := c.MustGet(ctx, "counter"); val == nil || *val != 3 {
+		t.Fatalf("counter=%v, want 3", val)
+	}
+	if val, err := c.GetOrCompute(ctx, "b", func(key string) (*int, error) { return viktor.Ptr(5), nil }); err != nil || *val != 5 {
+		t.Fatalf("GetOrCompute: val=%v, err=%v", val, err)
+	}
+	old := c.MustGet(ctx, "b")
+	if ok, _ := c.CompareAndSwap(ctx, "b", old, viktor.Ptr(6)); !ok || *c.MustGet(ctx, "b") != 6 {
+		t.Fatal("CompareAndSwap failed")
+	}
+}
+
+func TestLoadingCachePeek(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	c := cache.NewLoadingCache[string, int](
+		cache.WithClock[string, int](clock),
+		cache.WithCapacity[string, int](10),
+		cache.WithExpireAfterWrite[string, int](time.Minute),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) { return viktor.Ptr(1), nil }),
+	)
+	if _, ok := c.Peek(ctx, "a"); ok {
+		t.Fatal("Peek should not load")
 	}
-	for {
-		time.Sleep(time.Second * 3)
-		fmt.Printf("size:%d\n", c.Size())
+	c.Put(ctx, "a", viktor.Ptr(1))
+	c.Put(ctx, "b", viktor.Ptr(2))
+	if !c.Contains(ctx, "a") {
+		t.Fatal("Contains returned false for present key")
 	}
+	if keys := c.Keys(); fmt.Sprint(keys) != "[b a]" {
+		t.Fatalf("Keys=%v, want [b a]", keys)
+	}
+	clock.Advance(time.Minute)
+	if c.Contains(ctx, "a") || len(c.Keys()) != 0 {
+		t.Fatal("expired keys should not be visible")
+	}
+}
+
+func TestLoadingCacheExpireFunc(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	c := cache.NewLoadingCache[string, int](
+		cache.WithClock[string, int](clock),
+		cache.WithExpireFunc[string, int](func(_ context.Context, val *int) time.Duration {
+			return time.Duration(*val) * time.Second
+		}),
+	)
+	c.Put(ctx, "short", viktor.Ptr(20))
+	c.Put(ctx, "long", viktor.Ptr(1000))
+	c.Put(ctx, "never", viktor.Ptr(0))
+	if c.Contains(ctx, "never") {
+		t.Fatal("value with non-positive ttl should not be cached")
+	}
+	clock.Advance(time.Second * 19)
+	if !c.Contains(ctx, "short") {
+		t.Fatal("short expired too early")
+	}
+	clock.Advance(time.Second)
+	if c.Contains(ctx, "short") || !c.Contains(ctx, "long") {
+		t.Fatalf("unexpected keys %v", c.Keys())
+	}
+}
+
+func TestLoadingCacheMaxPinnedRatio(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLoadingCache(
+		cache.WithCapacity[string, int](4),
+		cache.WithMaxPinnedRatio[string, int](0.5),
+		cache.WithRecordStats[string, int](),
+	)
+	defer c.Close(ctx)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.PutWithOptions(ctx, key, viktor.Ptr(1), cache.Pinned)
+	}
+	// 只有a和b被固定(4*0.5=2个), 其余按普通元素淘汰, 新的写入不会被丢弃
+	for _, key := range []string{"x", "y", "z"} {
+		c.Put(ctx, key, viktor.Ptr(2))
+		if !c.Contains(ctx, key) {
+			t.Fatalf("%s was dropped, keys = %v", key, c.Keys())
+		}
+	}
+	if !c.Contains(ctx, "a") || !c.Contains(ctx, "b") || c.Contains(ctx, "c") || c.Contains(ctx, "d") {
+		t.Fatalf("keys = %v, want a and b pinned", c.Keys())
+	}
+	if n := c.Stats().PinRejections; n != 2 {
+		t.Fatalf("PinRejections = %d, want 2", n)
+	}
+}
+
+func TestLoadingCacheWriteDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	c := cache.NewLoadingCache[string, int](
+		cache.WithCapacity[string, int](10),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			started <- struct{}{}
+			<-release
+			return viktor.Ptr(1), nil
+		}),
+	)
+	defer c.Close(ctx)
 
+	// 加载期间写入的新值不会被加载的旧值覆盖
+	done := make(chan *int)
+	go func() { done <- c.MustGet(ctx, "a") }()
+	<-started
+	c.Put(ctx, "a", viktor.Ptr(2))
+	close(release)
+	if val := <-done; val == nil || *val != 2 {
+		t.Fatalf("Get returned %v, want 2", val)
+	}
+	if val := c.MustGet(ctx, "a"); val == nil || *val != 2 {
+		t.Fatalf("MustGet = %v, want 2", val)
+	}
+
+	// 加载期间删除的key不会被加载结果重新写入
+	release = make(chan struct{})
+	go func() { done <- c.MustGet(ctx, "b") }()
+	<-started
+	c.Remove(ctx, "b")
+	close(release)
+	<-done
+	if c.Contains(ctx, "b") {
+		t.Fatal("key removed during load was cached")
+	}
 }
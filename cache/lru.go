@@ -4,19 +4,31 @@ import (
 	"container/list"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 )
 
 // LRUCache (Least Recently Used，最近最少使用) 淘汰策略缓存
 type LRUCache[K, V any] struct {
-	cache map[string]*list.Element
-	list  *list.List
-	//mutex       sync.Mutex
-	conf *Config[K, V]
+	cache  map[string]*list.Element
+	list   *list.List
+	mutex  sync.Mutex
+	conf   *Config[K, V]
+	index  *keyIndex
+	stats  *statsCounter
+	events *eventHub[K, V]
+
+	pinned  int             // 固定的元素数量
+	special int             // 固定或者优先级不为0的元素数量, 为0时淘汰只需要删除最后一个元素
+	pending []removal[K, V] // 等待回调的删除通知, 见 unlock
 }
 
 type Entry[K, V any] struct {
-	key   K
-	value *V
+	key      K
+	value    *V
+	pinned   bool
+	priority int
+	refs     *valueRefs[K, V] // 当前值被 Acquire 持有的引用, 没有被持有过时为nil
 }
 
 // NewLRUCache 新建lru缓存(并发安全)
@@ -31,6 +43,13 @@ func NewLRUCache[K, V any](opts ...Option[K, V]) *LRUCache[K, V] {
 	for _, opt := range opts {
 		c.conf = opt(c.conf)
 	}
+	if c.conf.clock == nil {
+		// WithConfig 传入的配置可能没有设置时钟
+		c.conf.clock = SystemClock
+	}
+	c.index = newKeyIndex(c.conf.prefixIndex)
+	c.stats = newStatsCounter(c.conf.recordStats)
+	c.events = &eventHub[K, V]{}
 
 	return c
 }
@@ -39,73 +58,318 @@ func NewLRUCache[K, V any](opts ...Option[K, V]) *LRUCache[K, V] {
 // Key可以支持基础类型: string | int | int8 | int16 | int32 | int64 | float32 | float64 | uint8 | uint16 | uint32 | uint64 | bool
 // 以及实现了 String() string 接口的类
 func (lru *LRUCache[K, V]) Get(key K) (*V, error) {
-	//lru.mutex.Lock()
-	//defer lru.mutex.Unlock()
+	lru.mutex.Lock()
+	defer lru.unlock()
 
-	keyStr := lru.stringKey(key)
-	if elem, ok := lru.cache[keyStr]; ok {
-		lru.list.MoveToFront(elem)
-		return elem.Value.(*Entry[K, V]).value, nil
+	if entry, ok := lru.get(lru.stringKey(key)); ok {
+		lru.stats.recordHit()
+		lru.emit(EventHit, key, entry.value)
+		return entry.value, nil
 	}
+	lru.stats.recordMiss()
+	lru.emit(EventMiss, key, nil)
 	return nil, ErrorKeyNotFound
 }
 
 // MustGet 同 Get, 如果key不存在返回nil
 func (lru *LRUCache[K, V]) MustGet(key K) *V {
-	//lru.mutex.Lock()
-	//defer lru.mutex.Unlock()
+	val, _ := lru.Get(key)
+	return val
+}
 
-	keyStr := lru.stringKey(key)
-	if elem, ok := lru.cache[keyStr]; ok {
-		lru.list.MoveToFront(elem)
-		return elem.Value.(*Entry[K, V]).value
+// Peek 获取数据, 但不改变元素的访问顺序
+func (lru *LRUCache[K, V]) Peek(key K) (*V, bool) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	elem, ok := lru.cache[lru.stringKey(key)]
+	if !ok {
+		return nil, false
 	}
-	return nil
+	return elem.Value.(*Entry[K, V]).value, true
+}
+
+// Contains 判断key是否存在, 不改变元素的访问顺序
+func (lru *LRUCache[K, V]) Contains(key K) bool {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	_, ok := lru.cache[lru.stringKey(key)]
+	return ok
+}
+
+// Keys 获取全部key, 按最近使用到最久未使用排序, 不改变元素的访问顺序
+func (lru *LRUCache[K, V]) Keys() []K {
+	return lru.Iterator().Keys()
+}
+
+// Range 按最近使用到最久未使用的顺序遍历元素, fn返回false时停止遍历
+// 遍历的是调用时的快照, 不改变元素的访问顺序, fn中可以安全地访问缓存
+func (lru *LRUCache[K, V]) Range(fn func(key K, value *V) bool) {
+	lru.Iterator().Range(fn)
+}
+
+// Iterator 获取当前缓存的快照迭代器, 按最近使用到最久未使用排序
+func (lru *LRUCache[K, V]) Iterator() *Iterator[K, V] {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	entries := make([]Entry[K, V], 0, lru.list.Len())
+	for elem := lru.list.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, *elem.Value.(*Entry[K, V]))
+	}
+	return newIterator(entries)
 }
 
 // Put 设置缓存数据
 // Key可以支持基础类型: string | int | int8 | int16 | int32 | int64 | float32 | float64 | uint8 | uint16 | uint32 | uint64 | bool
 // 以及实现了 String() string 接口的类
 func (lru *LRUCache[K, V]) Put(key K, value *V) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	lru.put(lru.stringKey(key), key, value, nil)
+}
+
+// PutWithOptions 设置缓存数据, 并指定附加选项, 如 Tags
+func (lru *LRUCache[K, V]) PutWithOptions(key K, value *V, opts ...PutOption) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	lru.put(lru.stringKey(key), key, value, newPutOptions(opts))
+}
+
+// InvalidateTag 删除打了tag标签的全部元素, 返回删除的数量
+func (lru *LRUCache[K, V]) InvalidateTag(tag string) int {
+	return len(lru.invalidateTag(tag))
+}
+
+// invalidateTag 删除打了tag标签的全部元素, 返回删除的key
+func (lru *LRUCache[K, V]) invalidateTag(tag string) []string {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	keys := lru.index.keysWithTag(tag)
+	for _, strKey := range keys {
+		lru.remove(strKey, RemovalExplicit)
+	}
+	return keys
+}
+
+// InvalidatePrefix 删除编码后的key(见 WithKeyEncoder)以prefix开头的全部元素, 返回删除的数量
+// 开启 WithPrefixIndex 时通过前缀索引查找, 否则需要遍历全部元素
+func (lru *LRUCache[K, V]) InvalidatePrefix(prefix string) int {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	keys, ok := lru.index.keysWithPrefix(prefix)
+	if !ok {
+		for strKey := range lru.cache {
+			if strings.HasPrefix(strKey, prefix) {
+				keys = append(keys, strKey)
+			}
+		}
+	}
+	for _, strKey := range keys {
+		lru.remove(strKey, RemovalExplicit)
+	}
+	return len(keys)
+}
+
+// PutIfAbsent key不存在时写入value
+// 返回key当前对应的值, 以及key是否已经存在
+func (lru *LRUCache[K, V]) PutIfAbsent(key K, value *V) (*V, bool) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	strKey := lru.stringKey(key)
+	if entry, ok := lru.get(strKey); ok {
+		return entry.value, true
+	}
+	lru.put(strKey, key, value, nil)
+	return value, false
+}
+
+// GetOrCompute key存在时直接返回, 否则调用fn计算并写入缓存
+// fn在锁内执行, 同一个缓存同一时刻只会有一个fn在运行, fn返回error时不写入缓存
+func (lru *LRUCache[K, V]) GetOrCompute(key K, fn func(key K) (*V, error)) (*V, error) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	strKey := lru.stringKey(key)
+	if entry, ok := lru.get(strKey); ok {
+		return entry.value, nil
+	}
+	value, err := fn(key)
+	if err != nil {
+		return nil, err
+	}
+	lru.put(strKey, key, value, nil)
+	return value, nil
+}
+
+// Compute 原子地根据旧值计算新值
+// remapping的参数为旧值以及key是否存在, 返回新值以及是否保留; 不保留时删除key
+// 返回计算后的值, 以及key是否仍然存在
+func (lru *LRUCache[K, V]) Compute(key K, remapping func(old *V, ok bool) (*V, bool)) (*V, bool) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	strKey := lru.stringKey(key)
+	var old *V
+	entry, ok := lru.get(strKey)
+	if ok {
+		old = entry.value
+	}
+	return lru.applyCompute(strKey, key, old, ok, remapping)
+}
+
+// ComputeIfPresent key存在时原子地根据旧值计算新值, 不存在时不调用remapping
+// 返回计算后的值, 以及key是否仍然存在
+func (lru *LRUCache[K, V]) ComputeIfPresent(key K, remapping func(old *V) (*V, bool)) (*V, bool) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	strKey := lru.stringKey(key)
+	entry, ok := lru.get(strKey)
+	if !ok {
+		return nil, false
+	}
+	return lru.applyCompute(strKey, key, entry.value, true, func(old *V, _ bool) (*V, bool) {
+		return remapping(old)
+	})
+}
+
+// CompareAndSwap 当key存在且当前值与old是同一个指针时, 替换为new
+// 返回是否替换成功
+func (lru *LRUCache[K, V]) CompareAndSwap(key K, old, new *V) bool {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	strKey := lru.stringKey(key)
+	entry, ok := lru.get(strKey)
+	if !ok || entry.value != old {
+		return false
+	}
+	lru.replace(entry, new)
+	return true
+}
+
+func (lru *LRUCache[K, V]) applyCompute(strKey string, key K, old *V, ok bool, remapping func(old *V, ok bool) (*V, bool)) (*V, bool) {
+	value, keep := remapping(old, ok)
+	if !keep {
+		if ok {
+			lru.remove(strKey, RemovalExplicit)
+		}
+		return nil, false
+	}
+	lru.put(strKey, key, value, nil)
+	if _, exist := lru.cache[strKey]; !exist {
+		// capacity为0时不会写入
+		return nil, false
+	}
+	return value, true
+}
+
+// get 获取元素并移动到队首, 调用方需持有锁
+func (lru *LRUCache[K, V]) get(strKey string) (*Entry[K, V], bool) {
+	elem, ok := lru.cache[strKey]
+	if !ok {
+		return nil, false
+	}
+	lru.list.MoveToFront(elem)
+	return elem.Value.(*Entry[K, V]), true
+}
+
+// put 写入元素, options可以为nil, 调用方需持有锁
+func (lru *LRUCache[K, V]) put(strKey string, key K, value *V, options *putOptions) {
 	if lru.conf.capacity == 0 {
 		return
 	}
-	//lru.mutex.Lock()
-	//defer lru.mutex.Unlock()
-	strKey := lru.stringKey(key)
 
 	if elem, ok := lru.cache[strKey]; ok {
 		lru.list.MoveToFront(elem)
-		elem.Value.(*Entry[K, V]).value = value
+		entry := elem.Value.(*Entry[K, V])
+		lru.emit(EventPut, key, value)
+		lru.replace(entry, value)
+		if options != nil && options.hasTags {
+			lru.index.setTags(strKey, options.tags)
+		}
+		lru.setPlacement(entry, options)
 		return
 	}
 
-	if lru.list.Len() >= lru.conf.capacity {
-		// Remove the least recently used entry
-		lru.deleteLast()
+	if lru.list.Len() >= lru.conf.capacity && !lru.evict() {
+		// 全部元素都被固定, 无法写入
+		return
 	}
 
-	newEntry := &Entry[K, V]{key, value}
+	lru.emit(EventPut, key, value)
+	newEntry := &Entry[K, V]{key: key, value: value}
 	newElem := lru.list.PushFront(newEntry)
 	lru.cache[strKey] = newElem
+	lru.index.add(strKey)
+	if options != nil && options.hasTags {
+		lru.index.setTags(strKey, options.tags)
+	}
+	lru.setPlacement(newEntry, options)
+}
+
+// setPlacement 根据options修改元素的固定状态和优先级, 调用方需持有锁
+func (lru *LRUCache[K, V]) setPlacement(entry *Entry[K, V], options *putOptions) {
+	if options == nil || (!options.hasPinned && !options.hasPriority) {
+		return
+	}
+	if entry.pinned || entry.priority != 0 {
+		lru.special--
+	}
+	if options.hasPinned && options.pinned != entry.pinned {
+		if !options.pinned {
+			entry.pinned = false
+			lru.pinned--
+		} else if lru.pinned < lru.conf.maxPinned() {
+			entry.pinned = true
+			lru.pinned++
+		} else {
+			lru.stats.recordPinRejection()
+		}
+	}
+	if options.hasPriority {
+		entry.priority = options.priority
+	}
+	if entry.pinned || entry.priority != 0 {
+		lru.special++
+	}
 }
 
 // Clear 清空缓存
 func (lru *LRUCache[K, V]) Clear() {
-	//lru.mutex.Lock()
-	//defer lru.mutex.Unlock()
+	lru.mutex.Lock()
+	defer lru.unlock()
 
+	for elem := lru.list.Front(); elem != nil; elem = elem.Next() {
+		lru.retire(elem.Value.(*Entry[K, V]), RemovalExplicit)
+	}
 	lru.cache = make(map[string]*list.Element)
 	lru.list.Init()
+	lru.index.clear()
+	lru.pinned = 0
+	lru.special = 0
 }
 
 // Size 获取当前元素数量
 func (lru *LRUCache[K, V]) Size() int {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
 	return lru.list.Len()
 }
 
 func (lru *LRUCache[K, V]) IsFull() bool {
-	return lru.Size() >= lru.conf.capacity
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	return lru.list.Len() >= lru.conf.capacity
 }
 
 // Resize 重设缓存大小
@@ -113,18 +377,34 @@ func (lru *LRUCache[K, V]) Resize(capacity int) {
 	if capacity < 0 {
 		panic("capacity less than 0")
 	}
-	//lru.mutex.Lock()
-	//defer lru.mutex.Unlock()
+	lru.mutex.Lock()
+	defer lru.unlock()
 
-	for lru.list.Len() >= capacity {
-		lru.deleteLast()
+	// 固定的元素不会被淘汰, 缩容后元素数量可能仍然超过容量
+	for lru.list.Len() > capacity {
+		if !lru.evict() {
+			break
+		}
 	}
 	lru.conf.capacity = capacity
 }
 
+// Capacity 获取最大容量
+func (lru *LRUCache[K, V]) Capacity() int {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	return lru.conf.capacity
+}
+
+// Stats 获取统计数据, 需要通过 WithRecordStats 开启
+func (lru *LRUCache[K, V]) Stats() Stats {
+	return lru.stats.snapshot()
+}
+
 func (lru *LRUCache[K, V]) Print() {
-	//lru.mutex.Lock()
-	//defer lru.mutex.Unlock()
+	lru.mutex.Lock()
+	defer lru.unlock()
 	printf("capacity=%v\n", lru.conf.capacity)
 	for key, elem := range lru.cache {
 		val := elem.Value.(*Entry[K, V]).value
@@ -134,31 +414,98 @@ func (lru *LRUCache[K, V]) Print() {
 
 // Remove 删除元素
 func (lru *LRUCache[K, V]) Remove(key K) {
-	strKey := lru.stringKey(key)
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	lru.remove(lru.stringKey(key), RemovalExplicit)
+}
+
+// RemoveIf 删除满足condition的元素
+func (lru *LRUCache[K, V]) RemoveIf(condition func(K, *V) bool) {
+	lru.removeIf(condition, RemovalExplicit)
+}
+
+func (lru *LRUCache[K, V]) removeIf(condition func(K, *V) bool, cause RemovalCause) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	for strKey, elem := range lru.cache {
+		entry := elem.Value.(*Entry[K, V])
+		if condition(entry.key, entry.value) {
+			lru.remove(strKey, cause)
+		}
+	}
+}
+
+// removeStringKeys 按编码后的key删除元素, 返回实际删除的数量
+func (lru *LRUCache[K, V]) removeStringKeys(strKeys ...string) int {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	n := 0
+	for _, strKey := range strKeys {
+		if _, ok := lru.cache[strKey]; ok {
+			lru.remove(strKey, RemovalExplicit)
+			n++
+		}
+	}
+	return n
+}
+
+// remove 删除元素, 调用方需持有锁
+func (lru *LRUCache[K, V]) remove(strKey string, cause RemovalCause) {
 	elem, ok := lru.cache[strKey]
 	if !ok {
 		return
 	}
 	delete(lru.cache, strKey)
 	lru.list.Remove(elem)
+	lru.index.remove(strKey)
+	entry := elem.Value.(*Entry[K, V])
+	if entry.pinned || entry.priority != 0 {
+		lru.special--
+		if entry.pinned {
+			lru.pinned--
+		}
+	}
+	lru.retire(entry, cause)
 }
 
-func (lru *LRUCache[K, V]) RemoveIf(condition func(K, *V) bool) {
-	for _, elem := range lru.cache {
-		entry := elem.Value.(*Entry[K, V])
-		if condition(entry.key, entry.value) {
-			lru.Remove(entry.key)
-		}
+// replace 替换元素的值, 值不同时通知旧值被替换. 调用方需持有锁
+func (lru *LRUCache[K, V]) replace(entry *Entry[K, V], value *V) {
+	if entry.value != value {
+		lru.retire(entry, RemovalReplaced)
+		entry.value = value
 	}
 }
 
-// deleteLast 删除最后一个元素
-func (lru *LRUCache[K, V]) deleteLast() {
-	lastElem := lru.list.Back()
-	if lastElem == nil {
-		return
+// evictOne 加锁并淘汰一个元素, 见 evict
+func (lru *LRUCache[K, V]) evictOne() bool {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	return lru.evict()
+}
+
+// evict 淘汰一个元素: 未固定的元素中优先级最低的, 最久未使用的元素. 没有可以淘汰的元素时返回false, 调用方需持有锁
+// 没有固定或者设置了优先级的元素时直接淘汰最后一个元素, 否则需要从后往前遍历
+func (lru *LRUCache[K, V]) evict() bool {
+	victim := lru.list.Back()
+	if lru.special > 0 {
+		victim = nil
+		for elem := lru.list.Back(); elem != nil; elem = elem.Prev() {
+			entry := elem.Value.(*Entry[K, V])
+			if !entry.pinned && (victim == nil || entry.priority < victim.Value.(*Entry[K, V]).priority) {
+				victim = elem
+			}
+		}
+	}
+	if victim == nil {
+		return false
 	}
-	lru.Remove(lastElem.Value.(*Entry[K, V]).key)
+	lru.remove(lru.stringKey(victim.Value.(*Entry[K, V]).key), RemovalEvicted)
+	lru.stats.recordEviction()
+	return true
 }
 
 func (lru *LRUCache[K, V]) stringKey(key any) string {
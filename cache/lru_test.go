@@ -1,8 +1,9 @@
 package cache
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
@@ -61,37 +62,162 @@ func TestNewLRUCacheWithCustomKeyEncoder(t *testing.T) {
 	cache1.Print()
 }
 
-func TestParallelRunLRU(t *testing.T) {
-	cache := NewLRUCache[int, int](WithCapacity[int, int](20))
-	go func() {
-		for {
-			time.Sleep(time.Millisecond * 10)
-			key := rand.Intn(50)
-			cache.MustGet(key)
-			//printf("get key=%d, val=%v\n", key, cache.MustGet(key))
-		}
-	}()
-	go func() {
-		for {
-			time.Sleep(time.Millisecond * 10)
-			key := rand.Intn(50)
-			cache.Put(key, &key)
-			//printf("set key=%d, val=%d\n", key, key)
-		}
-	}()
-	go func() {
-		for {
-			time.Sleep(time.Millisecond * 10)
-			//c := rand.Intn(10) + 10
-			//cache.Resize(c)
-			//printf("reset capacity=%d\n", c)
-		}
-	}()
-	go func() {
-		for {
-			time.Sleep(time.Millisecond * 10)
-			cache.Print()
-		}
-	}()
-	time.Sleep(time.Second * 3600)
+func TestLRUCacheCompute(t *testing.T) {
+	cache := NewLRUCache(WithCapacity[string, int](10))
+	if val, loaded := cache.PutIfAbsent("a", viktor.Ptr(1)); loaded || *val != 1 {
+		t.Fatalf("PutIfAbsent on absent key: val=%v, loaded=%v", *val, loaded)
+	}
+	if val, loaded := cache.PutIfAbsent("a", viktor.Ptr(2)); !loaded || *val != 1 {
+		t.Fatalf("PutIfAbsent on present key: val=%v, loaded=%v", *val, loaded)
+	}
+
+	calls := 0
+	compute := func(key string) (*int, error) {
+		calls++
+		return viktor.Ptr(10), nil
+	}
+	if val, err := cache.GetOrCompute("b", compute); err != nil || *val != 10 {
+		t.Fatalf("GetOrCompute: val=%v, err=%v", val, err)
+	}
+	if _, err := cache.GetOrCompute("b", compute); err != nil || calls != 1 {
+		t.Fatalf("GetOrCompute should not recompute, calls=%d, err=%v", calls, err)
+	}
+
+	if _, ok := cache.ComputeIfPresent("c", func(old *int) (*int, bool) { return viktor.Ptr(1), true }); ok {
+		t.Fatal("ComputeIfPresent should not create absent key")
+	}
+	if val, ok := cache.ComputeIfPresent("b", func(old *int) (*int, bool) { return viktor.Ptr(*old + 1), true }); !ok || *val != 11 {
+		t.Fatalf("ComputeIfPresent: val=%v, ok=%v", val, ok)
+	}
+	if _, ok := cache.Compute("b", func(old *int, ok bool) (*int, bool) { return nil, false }); ok || cache.MustGet("b") != nil {
+		t.Fatal("Compute returning keep=false should remove key")
+	}
+
+	old := cache.MustGet("a")
+	if cache.CompareAndSwap("a", viktor.Ptr(1), viktor.Ptr(3)) {
+		t.Fatal("CompareAndSwap should compare pointers")
+	}
+	if !cache.CompareAndSwap("a", old, viktor.Ptr(3)) || *cache.MustGet("a") != 3 {
+		t.Fatal("CompareAndSwap failed")
+	}
+}
+
+func TestLRUCacheComputeParallel(t *testing.T) {
+	cache := NewLRUCache(WithCapacity[string, int](10))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				cache.Compute("counter", func(old *int, ok bool) (*int, bool) {
+					if !ok {
+						return viktor.Ptr(1), true
+					}
+					return viktor.Ptr(*old + 1), true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if val := cache.MustGet("counter"); *val != 10000 {
+		t.Fatalf("counter=%d, want 10000", *val)
+	}
+}
+
+func TestLRUCachePeekAndRange(t *testing.T) {
+	cache := NewLRUCache(WithCapacity[string, int](3))
+	cache.Put("a", viktor.Ptr(1))
+	cache.Put("b", viktor.Ptr(2))
+	cache.Put("c", viktor.Ptr(3))
+
+	if val, ok := cache.Peek("a"); !ok || *val != 1 {
+		t.Fatalf("Peek: val=%v, ok=%v", val, ok)
+	}
+	if !cache.Contains("a") || cache.Contains("d") {
+		t.Fatal("Contains returned wrong result")
+	}
+	// Peek 不改变顺序, a 仍然是最久未使用的
+	cache.Put("d", viktor.Ptr(4))
+	if cache.Contains("a") {
+		t.Fatal("Peek should not affect recency")
+	}
+	if keys := cache.Keys(); fmt.Sprint(keys) != "[d c b]" {
+		t.Fatalf("Keys=%v, want [d c b]", keys)
+	}
+
+	it := cache.Iterator()
+	cache.Remove("c")
+	cache.Put("e", viktor.Ptr(5))
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if fmt.Sprint(keys) != "[d c b]" {
+		t.Fatalf("snapshot keys=%v, want [d c b]", keys)
+	}
+
+	var visited []string
+	cache.Range(func(key string, value *int) bool {
+		visited = append(visited, key)
+		cache.MustGet(key)
+		return len(visited) < 2
+	})
+	if fmt.Sprint(visited) != "[e d]" {
+		t.Fatalf("Range visited=%v, want [e d]", visited)
+	}
+}
+
+func TestLRUPinnedAndPriority(t *testing.T) {
+	lru := NewLRUCache(WithCapacity[string, int](3), WithMaxPinnedRatio[string, int](0.5), WithRecordStats[string, int]())
+	lru.PutWithOptions("config", viktor.Ptr(0), Pinned)
+	lru.PutWithOptions("low", viktor.Ptr(1), Priority(-1))
+	lru.Put("a", viktor.Ptr(2))
+	// 最久未使用的是被固定的config, 应该先淘汰优先级低的low
+	lru.Put("b", viktor.Ptr(3))
+	if lru.Contains("low") || !lru.Contains("config") {
+		t.Fatalf("keys = %v, want low evicted", lru.Keys())
+	}
+	lru.Put("c", viktor.Ptr(4))
+	if lru.Contains("a") || !lru.Contains("config") {
+		t.Fatalf("keys = %v, want a evicted", lru.Keys())
+	}
+	// 超过固定比例(3*0.5=1个)时按普通元素写入
+	lru.PutWithOptions("b", viktor.Ptr(3), Pinned)
+	lru.Put("d", viktor.Ptr(5))
+	lru.Put("e", viktor.Ptr(6))
+	if lru.Contains("b") || lru.Stats().PinRejections != 1 {
+		t.Fatalf("keys = %v, b should not be pinned", lru.Keys())
+	}
+	// 不指定时保持原有的固定状态
+	lru.Put("config", viktor.Ptr(10))
+	lru.Resize(0)
+	if lru.Size() != 1 || *lru.MustGet("config") != 10 {
+		t.Fatalf("keys = %v, want only config left", lru.Keys())
+	}
+	lru.Resize(1)
+	lru.PutWithOptions("config", viktor.Ptr(10), Unpinned)
+	lru.Put("x", viktor.Ptr(11))
+	if lru.Contains("config") || !lru.Contains("x") {
+		t.Fatalf("keys = %v, want config evicted", lru.Keys())
+	}
+}
+
+func TestConfigWithoutClock(t *testing.T) {
+	// WithConfig 传入的配置没有设置时钟时使用 SystemClock
+	lru := NewLRUCache(WithConfig(&Config[string, int]{capacity: 10}))
+	lru.Put("a", viktor.Ptr(1))
+	if val, err := lru.Get("a"); err != nil || *val != 1 {
+		t.Fatalf("val=%v, err=%v", val, err)
+	}
+
+	ctx := context.Background()
+	c := NewLoadingCache(WithConfig(&Config[string, int]{capacity: 10, expireAfterWrite: time.Minute}))
+	defer c.Close(ctx)
+	if err := c.Put(ctx, "a", viktor.Ptr(1)); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.Get(ctx, "a"); err != nil || *val != 1 {
+		t.Fatalf("val=%v, err=%v", val, err)
+	}
 }
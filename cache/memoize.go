@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Memo 记忆化函数的缓存句柄, 用来让缓存的结果失效
+type Memo[K, V any] struct {
+	cache *LoadingCache[K, V]
+}
+
+// Invalidate 删除key对应的结果, 下一次调用时重新执行函数
+func (m *Memo[K, V]) Invalidate(ctx context.Context, key K) error {
+	return m.cache.Remove(ctx, key)
+}
+
+// InvalidateAll 删除全部结果
+func (m *Memo[K, V]) InvalidateAll(ctx context.Context) error {
+	return m.cache.Clear(ctx)
+}
+
+// Close 关闭底层的缓存, 见 LoadingCache.Close
+func (m *Memo[K, V]) Close(ctx context.Context) error {
+	return m.cache.Close(ctx)
+}
+
+// Cache 底层的缓存, 可以用来查看统计数据等
+func (m *Memo[K, V]) Cache() *LoadingCache[K, V] {
+	return m.cache
+}
+
+// Memoize 用 LoadingCache 缓存fn的结果, 返回与fn签名相同的函数
+// 同一个key的并发调用只会执行一次fn, 并共享第一个调用方的ctx; fn返回的错误不会被缓存
+// opts 同 NewLoadingCache, 如 WithCapacity, WithExpireAfterWrite; 开启了 WithClearInterval 等后台任务时, 使用完毕后需要调用 Memo.Close
+func Memoize[K, V any](fn func(ctx context.Context, key K) (V, error), opts ...Option[K, V]) (func(ctx context.Context, key K) (V, error), *Memo[K, V]) {
+	c := NewLoadingCache(opts...)
+	memoized := func(ctx context.Context, key K) (V, error) {
+		val, err := c.GetOrCompute(ctx, key, func(key K) (*V, error) {
+			val, err := fn(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			return &val, nil
+		})
+		if err != nil {
+			var zero V
+			return zero, err
+		}
+		return *val, nil
+	}
+	return memoized, &Memo[K, V]{cache: c}
+}
+
+// Key2 Memoize2 的组合key, 通过 String 编码, 各部分的编码方式与缓存的默认编码相同, 不支持的类型使用 %#v
+type Key2[A, B any] struct {
+	A A
+	B B
+}
+
+func (k Key2[A, B]) String() string {
+	return joinKeyParts(k.A, k.B)
+}
+
+// Key3 Memoize3 的组合key, 见 Key2
+type Key3[A, B, C any] struct {
+	A A
+	B B
+	C C
+}
+
+func (k Key3[A, B, C]) String() string {
+	return joinKeyParts(k.A, k.B, k.C)
+}
+
+// Memo2 Memoize2 的缓存句柄
+type Memo2[A, B, V any] struct {
+	*Memo[Key2[A, B], V]
+}
+
+// InvalidateArgs 删除参数(a, b)对应的结果
+func (m *Memo2[A, B, V]) InvalidateArgs(ctx context.Context, a A, b B) error {
+	return m.Invalidate(ctx, Key2[A, B]{a, b})
+}
+
+// Memo3 Memoize3 的缓存句柄
+type Memo3[A, B, C, V any] struct {
+	*Memo[Key3[A, B, C], V]
+}
+
+// InvalidateArgs 删除参数(a, b, c)对应的结果
+func (m *Memo3[A, B, C, V]) InvalidateArgs(ctx context.Context, a A, b B, c C) error {
+	return m.Invalidate(ctx, Key3[A, B, C]{a, b, c})
+}
+
+// Memoize2 两个参数的 Memoize, 以 Key2 作为缓存key
+func Memoize2[A, B, V any](fn func(ctx context.Context, a A, b B) (V, error), opts ...Option[Key2[A, B], V]) (func(ctx context.Context, a A, b B) (V, error), *Memo2[A, B, V]) {
+	get, memo := Memoize(func(ctx context.Context, key Key2[A, B]) (V, error) {
+		return fn(ctx, key.A, key.B)
+	}, opts...)
+	return func(ctx context.Context, a A, b B) (V, error) {
+		return get(ctx, Key2[A, B]{a, b})
+	}, &Memo2[A, B, V]{memo}
+}
+
+// Memoize3 三个参数的 Memoize, 以 Key3 作为缓存key
+func Memoize3[A, B, C, V any](fn func(ctx context.Context, a A, b B, c C) (V, error), opts ...Option[Key3[A, B, C], V]) (func(ctx context.Context, a A, b B, c C) (V, error), *Memo3[A, B, C, V]) {
+	get, memo := Memoize(func(ctx context.Context, key Key3[A, B, C]) (V, error) {
+		return fn(ctx, key.A, key.B, key.C)
+	}, opts...)
+	return func(ctx context.Context, a A, b B, c C) (V, error) {
+		return get(ctx, Key3[A, B, C]{a, b, c})
+	}, &Memo3[A, B, C, V]{memo}
+}
+
+// joinKeyParts 每一部分以 "长度:内容" 的形式拼接, 内容中包含分隔符也不会冲突
+func joinKeyParts(parts ...any) string {
+	var b strings.Builder
+	for _, part := range parts {
+		s := keyPartString(part)
+		b.WriteString(strconv.Itoa(len(s)))
+		b.WriteByte(':')
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+func keyPartString(part any) string {
+	switch data := part.(type) {
+	case string:
+		return data
+	case int, int8, int16, int32, int64, float32, float64, uint8, uint16, uint32, uint64, bool:
+		return fmt.Sprint(part)
+	case fmt.Stringer:
+		return data.String()
+	default:
+		return fmt.Sprintf("%#v", part)
+	}
+}
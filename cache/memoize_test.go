@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	// 10个并发调用都未命中后fn才返回
+	gate := make(chan struct{})
+	square, memo := Memoize(func(ctx context.Context, n int) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-gate
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return n * n, nil
+	}, WithClearInterval[int, int](time.Minute))
+	defer memo.Close(ctx)
+	misses := memo.Cache().Events(ctx, func(event Event[int, int]) bool { return event.Type == EventMiss })
+	go func() {
+		for i := 0; i < 10; i++ {
+			<-misses
+		}
+		close(gate)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := square(ctx, 3); err != nil || v != 9 {
+				t.Error(v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("want 1 call, got %d", calls)
+	}
+
+	// 错误不会被缓存
+	square(ctx, -1)
+	if _, err := square(ctx, -1); err == nil || calls != 3 {
+		t.Fatal("error should not be cached", calls)
+	}
+
+	memo.Invalidate(ctx, 3)
+	square(ctx, 3)
+	if calls != 4 {
+		t.Fatal("invalidate should drop the result")
+	}
+}
+
+func TestMemoize2(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	join, memo := Memoize2(func(ctx context.Context, a string, b string) (string, error) {
+		calls++
+		return a + b, nil
+	})
+	join(ctx, "ab", "c")
+	join(ctx, "a", "bc")
+	if calls != 2 || memo.Cache().Size() != 2 {
+		t.Fatal("composite keys collided")
+	}
+	join(ctx, "ab", "c")
+	memo.InvalidateArgs(ctx, "a", "bc")
+	join(ctx, "a", "bc")
+	if calls != 3 {
+		t.Fatal(calls)
+	}
+
+	sum, _ := Memoize3(func(ctx context.Context, a int, b float64, c time.Duration) (float64, error) {
+		return float64(a) + b + c.Seconds(), nil
+	})
+	if v, _ := sum(ctx, 1, 0.5, time.Second); v != 2.5 {
+		t.Fatal(v)
+	}
+	if s := (Key3[int, string, []int]{1, "x", []int{2}}).String(); s != "1:11:x8:[]int{2}" {
+		t.Fatal(s)
+	}
+}
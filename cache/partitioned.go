@@ -0,0 +1,351 @@
+package cache
+
+import (
+	"math"
+	"sync"
+)
+
+// PartitionConfig PartitionedCache 的配置
+type PartitionConfig[K, V any] struct {
+	maxEntries     int                         // 每个分区默认的最大元素数量, 0表示只受全局容量限制
+	maxWeight      int64                       // 每个分区默认的最大权重, 0表示不限制
+	limits         map[string]PartitionLimit   // 指定分区的限制, 优先于默认值
+	globalCapacity int                         // 全部分区共享的最大元素数量, 0表示不限制
+	weigher        func(key K, value *V) int64 // 计算元素的权重, 默认每个元素为1
+	cacheOptions   []Option[K, V]              // 创建每个分区的 LRUCache 时使用的配置
+}
+
+// PartitionLimit 单个分区的限制, 0表示不限制
+type PartitionLimit struct {
+	MaxEntries int
+	MaxWeight  int64
+}
+
+type PartitionOption[K, V any] func(conf *PartitionConfig[K, V]) *PartitionConfig[K, V]
+
+func NewDefaultPartitionConf[K, V any]() *PartitionConfig[K, V] {
+	return &PartitionConfig[K, V]{
+		globalCapacity: 1000,
+		limits:         make(map[string]PartitionLimit),
+		weigher: func(K, *V) int64 {
+			return 1
+		},
+	}
+}
+
+// WithPartitionMaxEntries 每个分区默认的最大元素数量
+func WithPartitionMaxEntries[K, V any](maxEntries int) PartitionOption[K, V] {
+	if maxEntries < 0 {
+		panic("maxEntries less than 0")
+	}
+	return func(conf *PartitionConfig[K, V]) *PartitionConfig[K, V] {
+		conf.maxEntries = maxEntries
+		return conf
+	}
+}
+
+// WithPartitionMaxWeight 每个分区默认的最大权重, 见 WithWeigher
+func WithPartitionMaxWeight[K, V any](maxWeight int64) PartitionOption[K, V] {
+	if maxWeight < 0 {
+		panic("maxWeight less than 0")
+	}
+	return func(conf *PartitionConfig[K, V]) *PartitionConfig[K, V] {
+		conf.maxWeight = maxWeight
+		return conf
+	}
+}
+
+// WithPartitionLimit 指定某个分区的限制, 例如为付费租户设置更大的配额
+func WithPartitionLimit[K, V any](partition string, limit PartitionLimit) PartitionOption[K, V] {
+	if limit.MaxEntries < 0 || limit.MaxWeight < 0 {
+		panic("invalid partition limit")
+	}
+	return func(conf *PartitionConfig[K, V]) *PartitionConfig[K, V] {
+		conf.limits[partition] = limit
+		return conf
+	}
+}
+
+// WithGlobalCapacity 全部分区共享的最大元素数量, 默认为1000
+// 超过时从元素最多的分区淘汰, 因此没有用满配额的分区的空闲容量可以被其他分区使用
+func WithGlobalCapacity[K, V any](capacity int) PartitionOption[K, V] {
+	if capacity < 0 {
+		panic("capacity less than 0")
+	}
+	return func(conf *PartitionConfig[K, V]) *PartitionConfig[K, V] {
+		conf.globalCapacity = capacity
+		return conf
+	}
+}
+
+// WithWeigher 计算元素的权重, 用于 WithPartitionMaxWeight, 同一个元素的权重必须保持不变
+func WithWeigher[K, V any](weigher func(key K, value *V) int64) PartitionOption[K, V] {
+	return func(conf *PartitionConfig[K, V]) *PartitionConfig[K, V] {
+		conf.weigher = weigher
+		return conf
+	}
+}
+
+// WithPartitionCacheOptions 创建每个分区的 LRUCache 时使用的配置, 容量由分区的限制决定, 设置的容量不生效
+func WithPartitionCacheOptions[K, V any](opts ...Option[K, V]) PartitionOption[K, V] {
+	return func(conf *PartitionConfig[K, V]) *PartitionConfig[K, V] {
+		conf.cacheOptions = append(conf.cacheOptions, opts...)
+		return conf
+	}
+}
+
+// PartitionInfo 分区的状态
+type PartitionInfo struct {
+	Size       int   `json:"size"`
+	Weight     int64 `json:"weight"`
+	MaxEntries int   `json:"maxEntries"`
+	MaxWeight  int64 `json:"maxWeight"`
+	Stats      Stats `json:"stats"`
+}
+
+// PartitionedCache 多租户的分区缓存(并发安全), 每个分区是一个独立的 LRUCache
+// 通过partitioner从key中提取分区名, 每个分区有自己的元素数量和权重上限, 一个分区写满只会淘汰自己的元素;
+// 全部分区共享全局容量, 超过时从当前元素最多的分区淘汰最久未使用的元素, 使各分区趋向公平的份额.
+// 没有通过 WithPartitionLimit 指定限制的分区变为空时被删除, 其统计数据随之丢弃
+type PartitionedCache[K, V any] struct {
+	mutex       sync.RWMutex
+	conf        *PartitionConfig[K, V]
+	partitioner func(key K) string
+	partitions  map[string]*partition[K, V]
+	listener    RemovalListener[K, V]
+	pending     []removal[K, V]
+}
+
+type partition[K, V any] struct {
+	cache  *LRUCache[K, V]
+	limit  PartitionLimit
+	weight int64
+}
+
+// NewPartitionedCache 新建分区缓存, partitioner 从key中提取分区名, 例如租户ID
+func NewPartitionedCache[K, V any](partitioner func(key K) string, opts ...PartitionOption[K, V]) *PartitionedCache[K, V] {
+	c := &PartitionedCache[K, V]{
+		conf:        NewDefaultPartitionConf[K, V](),
+		partitioner: partitioner,
+		partitions:  make(map[string]*partition[K, V]),
+	}
+	for _, opt := range opts {
+		c.conf = opt(c.conf)
+	}
+	conf := NewDefaultConf[K, V]()
+	for _, opt := range c.conf.cacheOptions {
+		conf = opt(conf)
+	}
+	c.listener = conf.removalListener
+	return c
+}
+
+// Get 获取数据
+func (c *PartitionedCache[K, V]) Get(key K) (*V, error) {
+	p, ok := c.getPartition(c.partitioner(key))
+	if !ok {
+		return nil, ErrorKeyNotFound
+	}
+	return p.cache.Get(key)
+}
+
+// MustGet 同 Get, 如果key不存在返回nil
+func (c *PartitionedCache[K, V]) MustGet(key K) *V {
+	val, _ := c.Get(key)
+	return val
+}
+
+// Contains 判断key是否存在, 不改变元素的访问顺序
+func (c *PartitionedCache[K, V]) Contains(key K) bool {
+	p, ok := c.getPartition(c.partitioner(key))
+	return ok && p.cache.Contains(key)
+}
+
+// Put 设置缓存数据, 权重超过分区最大权重的元素不会被缓存
+func (c *PartitionedCache[K, V]) Put(key K, value *V) {
+	c.PutWithOptions(key, value)
+}
+
+// PutWithOptions 设置缓存数据, 并指定附加选项, 见 LRUCache.PutWithOptions
+func (c *PartitionedCache[K, V]) PutWithOptions(key K, value *V, opts ...PutOption) {
+	c.mutex.Lock()
+	defer c.unlock()
+
+	name := c.partitioner(key)
+	p := c.partition(name)
+	weight := c.conf.weigher(key, value)
+	if p.limit.MaxWeight > 0 && weight > p.limit.MaxWeight {
+		p.cache.Remove(key)
+		c.dropIfEmpty(name)
+		return
+	}
+	old, exists := p.cache.Peek(key)
+	p.cache.PutWithOptions(key, value, opts...)
+	if !p.cache.Contains(key) {
+		c.dropIfEmpty(name)
+		return
+	}
+	// 替换旧值时按差值调整权重, 替换的删除通知不会修改权重
+	if exists {
+		weight -= c.conf.weigher(key, old)
+	}
+	p.weight += weight
+	for p.limit.MaxWeight > 0 && p.weight > p.limit.MaxWeight && p.cache.Size() > 1 {
+		if !p.cache.evictOne() {
+			break
+		}
+	}
+	if c.conf.globalCapacity > 0 {
+		c.enforceGlobalCapacity(name)
+	}
+}
+
+// Remove 删除元素
+func (c *PartitionedCache[K, V]) Remove(key K) {
+	c.mutex.Lock()
+	defer c.unlock()
+
+	name := c.partitioner(key)
+	if p, ok := c.partitions[name]; ok {
+		p.cache.Remove(key)
+		c.dropIfEmpty(name)
+	}
+}
+
+// Clear 清空全部分区
+func (c *PartitionedCache[K, V]) Clear() {
+	c.mutex.Lock()
+	defer c.unlock()
+
+	for name, p := range c.partitions {
+		p.cache.Clear()
+		c.dropIfEmpty(name)
+	}
+}
+
+// Size 全部分区的元素数量
+func (c *PartitionedCache[K, V]) Size() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.size()
+}
+
+// Partition 获取分区的状态
+func (c *PartitionedCache[K, V]) Partition(name string) (PartitionInfo, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	p, ok := c.partitions[name]
+	if !ok {
+		return PartitionInfo{}, false
+	}
+	return p.info(), true
+}
+
+// Partitions 获取全部分区的状态
+func (c *PartitionedCache[K, V]) Partitions() map[string]PartitionInfo {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	infos := make(map[string]PartitionInfo, len(c.partitions))
+	for name, p := range c.partitions {
+		infos[name] = p.info()
+	}
+	return infos
+}
+
+// enforceGlobalCapacity 超过全局容量时, 从元素最多的分区淘汰, 数量相同时优先淘汰刚写入的分区, 其次是名字最小的分区. 调用方需持有锁
+// 超出全局容量时必然有分区超过了它的公平份额, 而元素最多的分区一定是其中之一
+func (c *PartitionedCache[K, V]) enforceGlobalCapacity(current string) {
+	for size := c.size(); size > c.conf.globalCapacity; size-- {
+		victim, victimSize := "", -1
+		for name, p := range c.partitions {
+			n := p.cache.Size()
+			if n > victimSize || (n == victimSize && victim != current && (name == current || name < victim)) {
+				victim, victimSize = name, n
+			}
+		}
+		if victimSize <= 0 || !c.partitions[victim].cache.evictOne() {
+			return
+		}
+		c.dropIfEmpty(victim)
+	}
+}
+
+// dropIfEmpty 删除没有元素, 也没有指定限制的分区. 调用方需持有写锁
+func (c *PartitionedCache[K, V]) dropIfEmpty(name string) {
+	if p, ok := c.partitions[name]; ok && p.cache.Size() == 0 {
+		if _, explicit := c.conf.limits[name]; !explicit {
+			delete(c.partitions, name)
+		}
+	}
+}
+
+func (c *PartitionedCache[K, V]) getPartition(name string) (*partition[K, V], bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	p, ok := c.partitions[name]
+	return p, ok
+}
+
+// partition 获取分区, 不存在时创建. 调用方需持有写锁
+func (c *PartitionedCache[K, V]) partition(name string) *partition[K, V] {
+	if p, ok := c.partitions[name]; ok {
+		return p
+	}
+	limit, ok := c.conf.limits[name]
+	if !ok {
+		limit = PartitionLimit{MaxEntries: c.conf.maxEntries, MaxWeight: c.conf.maxWeight}
+	}
+	capacity := limit.MaxEntries
+	if capacity == 0 {
+		capacity = c.conf.globalCapacity
+	}
+	if capacity == 0 {
+		capacity = math.MaxInt32
+	}
+	p := &partition[K, V]{limit: limit}
+	opts := append(append([]Option[K, V](nil), c.conf.cacheOptions...),
+		WithCapacity[K, V](capacity),
+		WithRecordStats[K, V](),
+		WithRemovalListener[K, V](func(key K, value *V, cause RemovalCause) {
+			// 分区的操作都在持有c.mutex时进行, 这里直接更新权重, 用户的监听器在 unlock 时回调
+			// 替换的权重变化由 PutWithOptions 按差值计算
+			if cause != RemovalReplaced {
+				p.weight -= c.conf.weigher(key, value)
+			}
+			if c.listener != nil {
+				c.pending = append(c.pending, removal[K, V]{key: key, value: value, cause: cause})
+			}
+		}),
+	)
+	p.cache = NewLRUCache[K, V](opts...)
+	c.partitions[name] = p
+	return p
+}
+
+func (c *PartitionedCache[K, V]) size() int {
+	size := 0
+	for _, p := range c.partitions {
+		size += p.cache.Size()
+	}
+	return size
+}
+
+// unlock 释放写锁, 并在锁外回调持有锁期间产生的删除通知
+func (c *PartitionedCache[K, V]) unlock() {
+	pending := c.pending
+	c.pending = nil
+	c.mutex.Unlock()
+	for _, n := range pending {
+		c.listener(n.key, n.value, n.cause)
+	}
+}
+
+func (p *partition[K, V]) info() PartitionInfo {
+	return PartitionInfo{
+		Size:       p.cache.Size(),
+		Weight:     p.weight,
+		MaxEntries: p.limit.MaxEntries,
+		MaxWeight:  p.limit.MaxWeight,
+		Stats:      p.cache.Stats(),
+	}
+}
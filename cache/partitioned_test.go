@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	viktor "github.com/myron934/go-viktor"
+)
+
+func tenantOf(key string) string {
+	return strings.SplitN(key, "/", 2)[0]
+}
+
+func TestPartitionedCacheQuota(t *testing.T) {
+	c := NewPartitionedCache[string, int](tenantOf,
+		WithGlobalCapacity[string, int](100),
+		WithPartitionMaxEntries[string, int](10),
+		WithPartitionLimit[string, int]("vip", PartitionLimit{MaxEntries: 20}),
+	)
+	for i := 0; i < 5; i++ {
+		c.Put(fmt.Sprintf("quiet/%d", i), viktor.Ptr(i))
+	}
+	for i := 0; i < 1000; i++ {
+		c.Put(fmt.Sprintf("noisy/%d", i), viktor.Ptr(i))
+		c.Put(fmt.Sprintf("vip/%d", i), viktor.Ptr(i))
+	}
+	for i := 0; i < 5; i++ {
+		if !c.Contains(fmt.Sprintf("quiet/%d", i)) {
+			t.Fatalf("quiet/%d evicted by a noisy tenant", i)
+		}
+	}
+	infos := c.Partitions()
+	if infos["noisy"].Size != 10 || infos["vip"].Size != 20 || c.Size() != 35 {
+		t.Fatalf("partitions = %+v", infos)
+	}
+	c.Get("quiet/0")
+	c.Get("quiet/missing")
+	if info, _ := c.Partition("quiet"); info.Stats.Hits != 1 || info.Stats.Misses != 1 {
+		t.Fatalf("quiet stats = %+v", info.Stats)
+	}
+}
+
+func TestPartitionedCacheFairShare(t *testing.T) {
+	c := NewPartitionedCache[string, int](tenantOf, WithGlobalCapacity[string, int](30))
+	// 只有一个分区时可以使用全部容量
+	for i := 0; i < 30; i++ {
+		c.Put(fmt.Sprintf("a/%d", i), viktor.Ptr(i))
+	}
+	if info, _ := c.Partition("a"); info.Size != 30 {
+		t.Fatalf("a size = %d, want 30", info.Size)
+	}
+	// 新的分区从最大的分区抢占容量, 直到份额相同
+	for i := 0; i < 100; i++ {
+		c.Put(fmt.Sprintf("b/%d", i), viktor.Ptr(i))
+		c.Put(fmt.Sprintf("c/%d", i), viktor.Ptr(i))
+	}
+	infos := c.Partitions()
+	if infos["a"].Size != 10 || infos["b"].Size != 10 || infos["c"].Size != 10 {
+		t.Fatalf("partitions = %+v, want 10 each", infos)
+	}
+	// c空闲后, 其容量可以被其他分区使用
+	for i := 0; i < 100; i++ {
+		c.Remove(fmt.Sprintf("c/%d", i))
+	}
+	for i := 0; i < 100; i++ {
+		c.Put(fmt.Sprintf("b/x%d", i), viktor.Ptr(i))
+	}
+	if infos = c.Partitions(); infos["b"].Size != 20 || infos["a"].Size != 10 {
+		t.Fatalf("partitions = %+v, want a=10 b=20", infos)
+	}
+}
+
+func TestPartitionedCacheWeight(t *testing.T) {
+	var removed []string
+	c := NewPartitionedCache[string, string](tenantOf,
+		WithWeigher[string, string](func(key string, value *string) int64 { return int64(len(*value)) }),
+		WithPartitionMaxWeight[string, string](10),
+		WithPartitionCacheOptions[string, string](WithRemovalListener[string, string](func(key string, value *string, cause RemovalCause) {
+			removed = append(removed, key)
+		})),
+	)
+	c.Put("t/a", viktor.Ptr("aaaa"))
+	c.Put("t/b", viktor.Ptr("bbbb"))
+	c.Put("t/c", viktor.Ptr("cccc"))
+	if c.Contains("t/a") || len(removed) != 1 || removed[0] != "t/a" {
+		t.Fatalf("removed = %v, want [t/a]", removed)
+	}
+	c.Put("t/b", viktor.Ptr("b"))
+	if info, _ := c.Partition("t"); info.Weight != 5 || info.Size != 2 {
+		t.Fatalf("info = %+v, want weight 5", info)
+	}
+	// 重复写入同一个值不会重复计算权重
+	same := viktor.Ptr("cccc")
+	for i := 0; i < 5; i++ {
+		c.Put("t/d", same)
+	}
+	if info, _ := c.Partition("t"); info.Weight != 9 || info.Size != 3 {
+		t.Fatalf("info = %+v, want weight 9", info)
+	}
+	// 超过分区最大权重的元素不会被缓存
+	c.Put("t/huge", viktor.Ptr(strings.Repeat("x", 11)))
+	if c.Contains("t/huge") {
+		t.Fatal("entry heavier than the partition should not be cached")
+	}
+}
+
+func TestPartitionedCacheDropEmpty(t *testing.T) {
+	c := NewPartitionedCache[string, int](tenantOf,
+		WithGlobalCapacity[string, int](1),
+		WithPartitionLimit[string, int]("vip", PartitionLimit{MaxEntries: 5}),
+	)
+	c.Put("a/1", viktor.Ptr(1))
+	c.Put("vip/1", viktor.Ptr(1))
+	c.Remove("a/1")
+	c.Remove("vip/1")
+	// 指定了限制的分区保留, 其余的空分区被删除
+	if _, ok := c.Partition("a"); ok {
+		t.Fatal("empty partition a should be dropped")
+	}
+	if _, ok := c.Partition("vip"); !ok {
+		t.Fatal("partition with an explicit limit should be kept")
+	}
+	// 被全局容量淘汰空的分区也被删除, 数量相同时淘汰刚写入的c
+	c.Put("b/1", viktor.Ptr(1))
+	c.Put("c/1", viktor.Ptr(1))
+	if infos := c.Partitions(); len(infos) != 2 || infos["b"].Size != 1 {
+		t.Fatalf("partitions = %+v, want vip and b", infos)
+	}
+	c.Clear()
+	if infos := c.Partitions(); len(infos) != 1 {
+		t.Fatalf("partitions = %+v, want only vip after Clear", infos)
+	}
+}
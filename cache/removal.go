@@ -0,0 +1,171 @@
+package cache
+
+import "sync/atomic"
+
+// RemovalCause 元素被删除的原因
+type RemovalCause uint8
+
+const (
+	RemovalExplicit RemovalCause = iota + 1 // 调用 Remove, Clear, Invalidate 等方法删除
+	RemovalReplaced                         // 值被替换
+	RemovalEvicted                          // 容量不足被淘汰
+	RemovalExpired                          // 过期, 只有 LoadingCache 会产生
+)
+
+func (cause RemovalCause) String() string {
+	switch cause {
+	case RemovalExplicit:
+		return "explicit"
+	case RemovalReplaced:
+		return "replaced"
+	case RemovalEvicted:
+		return "evicted"
+	case RemovalExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// RemovalListener 元素被删除或替换后的回调, 在释放缓存的锁之后调用, 可以在回调中访问缓存
+// 对于 LRUCache, 被 Acquire 持有的值在全部 Handle 释放之后才会回调
+type RemovalListener[K, V any] func(key K, value *V, cause RemovalCause)
+
+type removal[K, V any] struct {
+	key   K
+	value *V
+	cause RemovalCause
+}
+
+// Handle Acquire 获取的值的引用, 使用完毕后必须调用 Release
+type Handle[V any] interface {
+	// Value 获取的值, Release 之后不应再使用
+	Value() *V
+	// Release 释放引用, 重复调用无效
+	Release()
+}
+
+// valueRefs 一个值被 Acquire 持有的引用计数
+type valueRefs[K, V any] struct {
+	count   int
+	retired *removal[K, V] // 值在被持有期间被删除或替换, 等待全部引用释放后通知
+}
+
+type lruHandle[K, V any] struct {
+	lru      *LRUCache[K, V]
+	refs     *valueRefs[K, V]
+	value    *V
+	released int32
+}
+
+func (h *lruHandle[K, V]) Value() *V {
+	return h.value
+}
+
+func (h *lruHandle[K, V]) Release() {
+	if !atomic.CompareAndSwapInt32(&h.released, 0, 1) {
+		return
+	}
+	h.lru.mutex.Lock()
+	defer h.lru.unlock()
+
+	h.refs.count--
+	if h.refs.count == 0 && h.refs.retired != nil {
+		h.lru.pending = append(h.lru.pending, *h.refs.retired)
+	}
+}
+
+// Acquire 获取key的值并持有引用, 与 Get 一样会移动到队首
+// 值在被持有期间仍然可能被淘汰, 删除或替换, 但 RemovalListener 会等到全部 Handle 释放之后才回调,
+// 因此可以在监听器中安全地关闭值所代表的资源
+func (lru *LRUCache[K, V]) Acquire(key K) (Handle[V], bool) {
+	lru.mutex.Lock()
+	defer lru.unlock()
+
+	entry, ok := lru.get(lru.stringKey(key))
+	if !ok {
+		lru.stats.recordMiss()
+		return nil, false
+	}
+	lru.stats.recordHit()
+	if entry.refs == nil {
+		entry.refs = &valueRefs[K, V]{}
+	}
+	entry.refs.count++
+	return &lruHandle[K, V]{lru: lru, refs: entry.refs, value: entry.value}, true
+}
+
+// retire 元素的值被删除或替换, 没有被持有时通知监听器, 否则等到全部 Handle 释放. 调用方需持有锁
+func (lru *LRUCache[K, V]) retire(entry *Entry[K, V], cause RemovalCause) {
+	if t, ok := removalEvent(cause); ok {
+		lru.emit(t, entry.key, entry.value)
+	}
+	refs := entry.refs
+	entry.refs = nil
+	if lru.conf.removalListener == nil {
+		return
+	}
+	n := removal[K, V]{key: entry.key, value: entry.value, cause: cause}
+	if refs != nil && refs.count > 0 {
+		refs.retired = &n
+		return
+	}
+	lru.pending = append(lru.pending, n)
+}
+
+// unlock 释放锁, 并在锁外回调持有锁期间产生的删除通知
+func (lru *LRUCache[K, V]) unlock() {
+	pending := lru.pending
+	lru.pending = nil
+	lru.mutex.Unlock()
+	for _, n := range pending {
+		lru.conf.removalListener(n.key, n.value, n.cause)
+	}
+}
+
+// unlock 释放锁, 并在锁外回调持有锁期间产生的删除通知
+func (lfu *LFUCache[V]) unlock() {
+	pending := lfu.pending
+	lfu.pending = nil
+	lfu.mutex.Unlock()
+	for _, n := range pending {
+		lfu.conf.removalListener(n.key, n.value, n.cause)
+	}
+}
+
+// notifyRemoval 记录删除通知, 在 unlock 时回调. 调用方需持有锁
+func (lfu *LFUCache[V]) notifyRemoval(item *LFUItem, cause RemovalCause) {
+	if t, ok := removalEvent(cause); ok {
+		lfu.emit(t, item.key, item.value.(*V))
+	}
+	if lfu.conf.removalListener != nil {
+		lfu.pending = append(lfu.pending, removal[any, V]{key: item.key, value: item.value.(*V), cause: cause})
+	}
+}
+
+// unlock 释放锁, 并在锁外回调持有锁期间内部缓存产生的删除通知
+func (c *LoadingCache[K, V]) unlock() {
+	pending := c.pending
+	c.pending = nil
+	c.mutex.Unlock()
+	for _, n := range pending {
+		c.conf.removalListener(n.key, n.value, n.cause)
+	}
+}
+
+// onInnerRemoval 内部lru的删除回调, 由持有 c.mutex 的操作触发, 转换为 LoadingCache 的删除通知
+// 已经过期的元素被淘汰或替换时, 原因记为 RemovalExpired
+func (c *LoadingCache[K, V]) onInnerRemoval(key K, item *LoadingItem[V], cause RemovalCause) {
+	if c.writeBehind != nil && (cause == RemovalEvicted || cause == RemovalExpired) {
+		// 脏数据不在缓存中以后尽快写回
+		c.writeBehind.flushIfDirty(c.stringKey(key))
+	}
+	if (cause == RemovalEvicted || cause == RemovalReplaced) && !c.now().Before(item.expire) {
+		cause = RemovalExpired
+	}
+	if t, ok := removalEvent(cause); ok {
+		c.emit(t, key, item.value, nil)
+	}
+	if c.conf.removalListener != nil {
+		c.pending = append(c.pending, removal[K, V]{key: key, value: item.value, cause: cause})
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+)
+
+type removalRecorder struct {
+	mutex   sync.Mutex
+	removed []string
+}
+
+func (r *removalRecorder) record(key string, cause RemovalCause) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.removed = append(r.removed, key+":"+cause.String())
+}
+
+func (r *removalRecorder) take() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	removed := r.removed
+	r.removed = nil
+	return removed
+}
+
+func assertRemoved(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("removed = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("removed = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLRURemovalListener(t *testing.T) {
+	recorder := &removalRecorder{}
+	var lru *LRUCache[string, int]
+	lru = NewLRUCache(
+		WithCapacity[string, int](2),
+		WithRemovalListener[string, int](func(key string, value *int, cause RemovalCause) {
+			// 监听器在锁外调用, 可以访问缓存
+			lru.Contains(key)
+			recorder.record(key, cause)
+		}),
+	)
+	lru.Put("a", viktor.Ptr(1))
+	lru.Put("a", viktor.Ptr(2))
+	lru.Put("b", viktor.Ptr(3))
+	lru.Put("c", viktor.Ptr(4))
+	lru.Remove("b")
+	lru.Clear()
+	assertRemoved(t, recorder.take(), "a:replaced", "a:evicted", "b:explicit", "c:explicit")
+}
+
+func TestLRUAcquire(t *testing.T) {
+	recorder := &removalRecorder{}
+	lru := NewLRUCache(
+		WithCapacity[string, int](1),
+		WithRemovalListener[string, int](func(key string, value *int, cause RemovalCause) {
+			recorder.record(key, cause)
+		}),
+	)
+	if _, ok := lru.Acquire("a"); ok {
+		t.Fatal("acquired missing key")
+	}
+	lru.Put("a", viktor.Ptr(1))
+	h1, _ := lru.Acquire("a")
+	h2, _ := lru.Acquire("a")
+	lru.Put("b", viktor.Ptr(2))
+	if lru.Contains("a") || *h1.Value() != 1 {
+		t.Fatal("a should be evicted while the handle keeps its value")
+	}
+	assertRemoved(t, recorder.take())
+	h1.Release()
+	h1.Release()
+	assertRemoved(t, recorder.take())
+	h2.Release()
+	assertRemoved(t, recorder.take(), "a:evicted")
+
+	// 替换后新值不受旧值的引用影响
+	h3, _ := lru.Acquire("b")
+	lru.Put("b", viktor.Ptr(3))
+	lru.Remove("b")
+	assertRemoved(t, recorder.take(), "b:explicit")
+	h3.Release()
+	assertRemoved(t, recorder.take(), "b:replaced")
+}
+
+func TestLFURemovalListener(t *testing.T) {
+	recorder := &removalRecorder{}
+	lfu := NewLFUCacheWithOptions[int](
+		WithCapacity[any, int](1),
+		WithRemovalListener[any, int](func(key any, value *int, cause RemovalCause) {
+			recorder.record(key.(string), cause)
+		}),
+	)
+	lfu.Put("a", viktor.Ptr(1))
+	lfu.Put("b", viktor.Ptr(2))
+	lfu.CompareAndSwap("b", lfu.Get("b"), viktor.Ptr(3))
+	lfu.Clear()
+	assertRemoved(t, recorder.take(), "a:evicted", "b:replaced", "b:explicit")
+}
+
+func TestLoadingCacheRemovalListener(t *testing.T) {
+	ctx := context.Background()
+	recorder := &removalRecorder{}
+	var c *LoadingCache[string, int]
+	c = NewLoadingCache(
+		WithCapacity[string, int](2),
+		WithExpireAfterWrite[string, int](time.Minute),
+		WithRemovalListener[string, int](func(key string, value *int, cause RemovalCause) {
+			c.Peek(ctx, key)
+			recorder.record(key, cause)
+		}),
+	)
+	c.Put(ctx, "a", viktor.Ptr(1))
+	c.Put(ctx, "b", viktor.Ptr(2))
+	c.Put(ctx, "c", viktor.Ptr(3))
+	c.Remove(ctx, "b")
+	assertRemoved(t, recorder.take(), "a:evicted", "b:explicit")
+}
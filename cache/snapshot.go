@@ -0,0 +1,484 @@
+package cache
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 快照文件格式(版本1):
+//
+//	magic "VKCS" | version(1 byte) | kind(1 byte) | 保存时间 varint(unix nano) | 元素数量 uvarint
+//	每个元素: key长度 uvarint | key | 是否有value(1 byte) | [value长度 uvarint | value] | meta varint
+//
+// meta 的含义由缓存类型决定: LRU 为0, LFU 为访问频率, LoadingCache 为保存时剩余的过期时间(纳秒)
+// LRU 和 LoadingCache 的元素按最近使用到最久未使用的顺序写入
+const (
+	snapshotMagic         = "VKCS"
+	snapshotVersion       = 1
+	snapshotMaxFieldBytes = 1 << 30
+)
+
+type snapshotKind uint8
+
+const (
+	snapshotKindLRU snapshotKind = iota + 1
+	snapshotKindLFU
+	snapshotKindLoading
+)
+
+// SnapshotCodec 快照中key和value的序列化方式, 未指定的字段使用json
+type SnapshotCodec[K, V any] struct {
+	KeySerializer     Serializer[K, []byte]
+	KeyDeserializer   Deserializer[[]byte, K]
+	ValueSerializer   Serializer[V, []byte]
+	ValueDeserializer Deserializer[[]byte, V]
+}
+
+// Snapshotter 支持快照持久化的缓存
+type Snapshotter interface {
+	SaveTo(w io.Writer) error
+	LoadFrom(r io.Reader) error
+}
+
+type snapshotRecord[K, V any] struct {
+	key   *K
+	value *V
+	meta  int64
+}
+
+// SaveTo 将缓存写入w, 保留最近使用顺序
+func (lru *LRUCache[K, V]) SaveTo(w io.Writer) error {
+	it := lru.Iterator()
+	records := make([]snapshotRecord[K, V], 0, it.Len())
+	for it.Next() {
+		key := it.Key()
+		records = append(records, snapshotRecord[K, V]{key: &key, value: it.Value()})
+	}
+	return writeSnapshot(w, snapshotKindLRU, lru.conf.snapshotCodec, time.Now(), records)
+}
+
+// LoadFrom 从r中读取 SaveTo 保存的快照并写入缓存, 恢复最近使用顺序
+// 快照中的元素比缓存中已有的元素更新, 超过容量时按 LRU 规则淘汰
+func (lru *LRUCache[K, V]) LoadFrom(r io.Reader) error {
+	_, records, err := readSnapshot(r, snapshotKindLRU, lru.conf.snapshotCodec)
+	if err != nil {
+		return err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		lru.Put(*records[i].key, records[i].value)
+	}
+	return nil
+}
+
+// SetSnapshotCodec 指定 SaveTo / LoadFrom 时key和value的序列化方式, 为nil时使用json
+// LFUCache 的key类型为any, json无法还原原来的类型(如int会变成float64), 因此没有指定 KeySerializer / KeyDeserializer 时只支持string类型的key
+func (lfu *LFUCache[V]) SetSnapshotCodec(codec *SnapshotCodec[any, V]) {
+	lfu.mutex.Lock()
+	defer lfu.mutex.Unlock()
+
+	lfu.conf.snapshotCodec = codec
+}
+
+// SaveTo 将缓存写入w, 保留访问频率
+func (lfu *LFUCache[V]) SaveTo(w io.Writer) error {
+	lfu.mutex.Lock()
+	codec := lfu.conf.snapshotCodec
+	records := make([]snapshotRecord[any, V], 0, len(lfu.pq))
+	for _, item := range lfu.pq {
+		key := item.key
+		if _, ok := key.(string); !ok && codec.keySerializer() == nil {
+			lfu.mutex.Unlock()
+			return fmt.Errorf("%w: key %v of type %T requires SnapshotCodec.KeySerializer", ErrorSnapshotSerialize, key, key)
+		}
+		records = append(records, snapshotRecord[any, V]{key: &key, value: item.value.(*V), meta: int64(item.frequency)})
+	}
+	lfu.mutex.Unlock()
+
+	return writeSnapshot(w, snapshotKindLFU, codec, time.Now(), records)
+}
+
+// LoadFrom 从r中读取 SaveTo 保存的快照并写入缓存, 恢复访问频率
+func (lfu *LFUCache[V]) LoadFrom(r io.Reader) error {
+	lfu.mutex.Lock()
+	codec := lfu.conf.snapshotCodec
+	lfu.mutex.Unlock()
+
+	if codec.keyDeserializer() == nil {
+		codec = withStringKeys(codec)
+	}
+	_, records, err := readSnapshot(r, snapshotKindLFU, codec)
+	if err != nil {
+		return err
+	}
+	lfu.mutex.Lock()
+	defer lfu.unlock()
+	for _, record := range records {
+		strKey := lfu.stringKey(*record.key)
+		lfu.put(strKey, *record.key, record.value, nil)
+		if item, ok := lfu.cache[strKey]; ok && record.meta > 0 {
+			item.frequency = int(record.meta)
+			heap.Fix(&lfu.pq, item.index)
+		}
+	}
+	return nil
+}
+
+// withStringKeys 返回key按json字符串反序列化的codec, 保证没有指定key的反序列化方式时LFUCache的key仍然是string
+func withStringKeys[V any](codec *SnapshotCodec[any, V]) *SnapshotCodec[any, V] {
+	c := &SnapshotCodec[any, V]{}
+	if codec != nil {
+		*c = *codec
+	}
+	c.KeyDeserializer = func(_ context.Context, data *[]byte) *any {
+		var key string
+		if err := json.Unmarshal(*data, &key); err != nil {
+			return nil
+		}
+		var k any = key
+		return &k
+	}
+	return c
+}
+
+// SaveTo 将未过期的元素写入w, 保留最近使用顺序以及剩余的过期时间
+func (c *LoadingCache[K, V]) SaveTo(w io.Writer) error {
+	it := c.lruCache.Iterator()
+	now := c.now()
+	records := make([]snapshotRecord[K, V], 0, it.Len())
+	for it.Next() {
+		item := it.Value()
+		if item == nil || !now.Before(item.expire) {
+			continue
+		}
+		key := it.Key()
+		records = append(records, snapshotRecord[K, V]{key: &key, value: item.value, meta: int64(item.expire.Sub(now))})
+	}
+	return writeSnapshot(w, snapshotKindLoading, c.conf.snapshotCodec, now, records)
+}
+
+// LoadFrom 从r中读取 SaveTo 保存的快照并写入缓存
+// 剩余过期时间会扣除从保存到现在经过的时间, 期间已经过期的元素会被丢弃
+func (c *LoadingCache[K, V]) LoadFrom(r io.Reader) error {
+	savedAt, records, err := readSnapshot(r, snapshotKindLoading, c.conf.snapshotCodec)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.unlock()
+	now := c.now()
+	elapsed := now.Sub(savedAt)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		remaining := time.Duration(records[i].meta) - elapsed
+		if remaining <= 0 {
+			continue
+		}
+		c.lruCache.Put(*records[i].key, &LoadingItem[V]{
+			expire: now.Add(remaining),
+			write:  now,
+			value:  records[i].value,
+		})
+	}
+	c.startSweeper()
+	return nil
+}
+
+// SaveToFile 将缓存快照保存到文件
+// 先写入同目录下的临时文件再重命名, 保存失败不会破坏已有的快照文件
+func SaveToFile(c Snapshotter, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = c.SaveTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFromFile 从 SaveToFile 保存的文件中恢复缓存
+// 文件不存在时返回的error满足 os.IsNotExist
+func LoadFromFile(c Snapshotter, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.LoadFrom(f)
+}
+
+// StartPeriodicSnapshot 启动后台协程, 每隔interval将缓存快照保存到path
+// 保存失败时调用onError(可以为nil). 返回的stop会停止后台协程, 并最后保存一次快照
+func StartPeriodicSnapshot(c Snapshotter, path string, interval time.Duration, onError func(err error)) (stop func() error) {
+	if interval <= 0 {
+		panic("interval must be greater than 0")
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := SaveToFile(c, path); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() {
+			close(done)
+			<-exited
+			err = SaveToFile(c, path)
+		})
+		return err
+	}
+}
+
+func writeSnapshot[K, V any](w io.Writer, kind snapshotKind, codec *SnapshotCodec[K, V], savedAt time.Time, records []snapshotRecord[K, V]) error {
+	ctx := context.Background()
+	enc := &snapshotEncoder{w: bufio.NewWriter(w)}
+	enc.writeRaw([]byte(snapshotMagic))
+	enc.writeRaw([]byte{snapshotVersion, byte(kind)})
+	enc.writeVarint(savedAt.UnixNano())
+	enc.writeUvarint(uint64(len(records)))
+	for _, record := range records {
+		key, err := serialize(ctx, codec.keySerializer(), record.key)
+		if err != nil {
+			return err
+		}
+		enc.writeBytes(key)
+		if record.value == nil {
+			enc.writeRaw([]byte{0})
+		} else {
+			value, err := serialize(ctx, codec.valueSerializer(), record.value)
+			if err != nil {
+				return err
+			}
+			enc.writeRaw([]byte{1})
+			enc.writeBytes(value)
+		}
+		enc.writeVarint(record.meta)
+		if enc.err != nil {
+			return enc.err
+		}
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+func readSnapshot[K, V any](r io.Reader, kind snapshotKind, codec *SnapshotCodec[K, V]) (time.Time, []snapshotRecord[K, V], error) {
+	ctx := context.Background()
+	dec := &snapshotDecoder{r: bufio.NewReader(r)}
+	header := dec.readRaw(len(snapshotMagic) + 2)
+	if dec.err != nil || string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return time.Time{}, nil, ErrorSnapshotFormat
+	}
+	if header[len(snapshotMagic)] != snapshotVersion {
+		return time.Time{}, nil, fmt.Errorf("%w: %d", ErrorSnapshotVersion, header[len(snapshotMagic)])
+	}
+	if snapshotKind(header[len(snapshotMagic)+1]) != kind {
+		return time.Time{}, nil, ErrorSnapshotKind
+	}
+	savedAt := time.Unix(0, dec.readVarint())
+	count := dec.readUvarint()
+	if dec.err != nil {
+		return time.Time{}, nil, dec.err
+	}
+	records := make([]snapshotRecord[K, V], 0)
+	for i := uint64(0); i < count; i++ {
+		var record snapshotRecord[K, V]
+		keyData := dec.readBytes()
+		hasValue := dec.readRaw(1)
+		if dec.err != nil {
+			return time.Time{}, nil, dec.err
+		}
+		key, err := deserialize(ctx, codec.keyDeserializer(), keyData)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		record.key = key
+		if hasValue[0] == 1 {
+			valueData := dec.readBytes()
+			if dec.err != nil {
+				return time.Time{}, nil, dec.err
+			}
+			if record.value, err = deserialize(ctx, codec.valueDeserializer(), valueData); err != nil {
+				return time.Time{}, nil, err
+			}
+		}
+		record.meta = dec.readVarint()
+		if dec.err != nil {
+			return time.Time{}, nil, dec.err
+		}
+		records = append(records, record)
+	}
+	return savedAt, records, nil
+}
+
+func (codec *SnapshotCodec[K, V]) keySerializer() Serializer[K, []byte] {
+	if codec == nil {
+		return nil
+	}
+	return codec.KeySerializer
+}
+
+func (codec *SnapshotCodec[K, V]) keyDeserializer() Deserializer[[]byte, K] {
+	if codec == nil {
+		return nil
+	}
+	return codec.KeyDeserializer
+}
+
+func (codec *SnapshotCodec[K, V]) valueSerializer() Serializer[V, []byte] {
+	if codec == nil {
+		return nil
+	}
+	return codec.ValueSerializer
+}
+
+func (codec *SnapshotCodec[K, V]) valueDeserializer() Deserializer[[]byte, V] {
+	if codec == nil {
+		return nil
+	}
+	return codec.ValueDeserializer
+}
+
+func serialize[T any](ctx context.Context, serializer Serializer[T, []byte], t *T) ([]byte, error) {
+	if serializer == nil {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrorSnapshotSerialize, err)
+		}
+		return data, nil
+	}
+	data := serializer(ctx, t)
+	if data == nil {
+		return nil, ErrorSnapshotSerialize
+	}
+	return *data, nil
+}
+
+func deserialize[T any](ctx context.Context, deserializer Deserializer[[]byte, T], data []byte) (*T, error) {
+	if deserializer == nil {
+		t := new(T)
+		if err := json.Unmarshal(data, t); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrorSnapshotDeserialize, err)
+		}
+		return t, nil
+	}
+	t := deserializer(ctx, &data)
+	if t == nil {
+		return nil, ErrorSnapshotDeserialize
+	}
+	return t, nil
+}
+
+// snapshotEncoder 写入快照, 出错后后续写入都会被忽略, 错误记录在err中
+type snapshotEncoder struct {
+	w   *bufio.Writer
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (e *snapshotEncoder) writeRaw(data []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(data)
+}
+
+func (e *snapshotEncoder) writeUvarint(x uint64) {
+	n := binary.PutUvarint(e.buf[:], x)
+	e.writeRaw(e.buf[:n])
+}
+
+func (e *snapshotEncoder) writeVarint(x int64) {
+	n := binary.PutVarint(e.buf[:], x)
+	e.writeRaw(e.buf[:n])
+}
+
+func (e *snapshotEncoder) writeBytes(data []byte) {
+	e.writeUvarint(uint64(len(data)))
+	e.writeRaw(data)
+}
+
+// snapshotDecoder 读取快照, 出错后后续读取都返回零值, 错误记录在err中
+type snapshotDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *snapshotDecoder) readRaw(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		d.err = ErrorSnapshotFormat
+	}
+	return data
+}
+
+func (d *snapshotDecoder) readUvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.err = ErrorSnapshotFormat
+	}
+	return x
+}
+
+func (d *snapshotDecoder) readVarint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.err = ErrorSnapshotFormat
+	}
+	return x
+}
+
+func (d *snapshotDecoder) readBytes() []byte {
+	n := d.readUvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > snapshotMaxFieldBytes {
+		d.err = ErrorSnapshotFormat
+		return nil
+	}
+	return d.readRaw(int(n))
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+)
+
+func TestLRUCacheSnapshot(t *testing.T) {
+	cache := NewLRUCache(WithCapacity[string, int](10))
+	cache.Put("a", viktor.Ptr(1))
+	cache.Put("b", viktor.Ptr(2))
+	cache.Put("nil", nil)
+	cache.Put("c", viktor.Ptr(3))
+	cache.MustGet("a")
+
+	var buf bytes.Buffer
+	if err := cache.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewLRUCache(WithCapacity[string, int](10))
+	if err := restored.LoadFrom(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if keys := restored.Keys(); fmt.Sprint(keys) != "[a c nil b]" {
+		t.Fatalf("Keys=%v, want [a c nil b]", keys)
+	}
+	if val, ok := restored.Peek("nil"); !ok || val != nil {
+		t.Fatalf("nil value not restored: val=%v, ok=%v", val, ok)
+	}
+	if val := restored.MustGet("c"); val == nil || *val != 3 {
+		t.Fatalf("c=%v, want 3", val)
+	}
+
+	if err := NewLFUCache[int](10).LoadFrom(bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrorSnapshotKind) {
+		t.Fatalf("err=%v, want ErrorSnapshotKind", err)
+	}
+	if err := restored.LoadFrom(bytes.NewReader([]byte("garbage"))); !errors.Is(err, ErrorSnapshotFormat) {
+		t.Fatalf("err=%v, want ErrorSnapshotFormat", err)
+	}
+}
+
+func TestLFUCacheSnapshot(t *testing.T) {
+	cache := NewLFUCache[int](3)
+	cache.Put("a", viktor.Ptr(1))
+	cache.Put("b", viktor.Ptr(2))
+	cache.Put("c", viktor.Ptr(3))
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("c")
+
+	var buf bytes.Buffer
+	if err := cache.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewLFUCache[int](3)
+	if err := restored.LoadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := restored.Keys(); fmt.Sprint(keys) != "[a c b]" {
+		t.Fatalf("Keys=%v, want [a c b]", keys)
+	}
+}
+
+func TestLFUCacheSnapshotIntKeys(t *testing.T) {
+	cache := NewLFUCache[int](3)
+	cache.Put(1, viktor.Ptr(1))
+	cache.Put(1<<60+1, viktor.Ptr(2))
+
+	// 默认的json无法还原int类型的key
+	var buf bytes.Buffer
+	if err := cache.SaveTo(&buf); !errors.Is(err, ErrorSnapshotSerialize) {
+		t.Fatalf("err=%v, want ErrorSnapshotSerialize", err)
+	}
+	codec := &SnapshotCodec[any, int]{
+		KeySerializer: func(_ context.Context, key *any) *[]byte {
+			return viktor.Ptr([]byte(strconv.Itoa((*key).(int))))
+		},
+		KeyDeserializer: func(_ context.Context, data *[]byte) *any {
+			key, err := strconv.Atoi(string(*data))
+			if err != nil {
+				return nil
+			}
+			var k any = key
+			return &k
+		},
+	}
+	cache.SetSnapshotCodec(codec)
+	if err := cache.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewLFUCache[int](3)
+	restored.SetSnapshotCodec(codec)
+	if err := restored.LoadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if val := restored.Get(1<<60 + 1); val == nil || *val != 2 {
+		t.Fatalf("val=%v, want 2", val)
+	}
+	if val := restored.Get(1); val == nil || *val != 1 {
+		t.Fatalf("val=%v, want 1", val)
+	}
+}
+
+func TestLoadingCacheSnapshot(t *testing.T) {
+	ctx := context.Background()
+	codec := &SnapshotCodec[int, string]{
+		KeySerializer: func(_ context.Context, key *int) *[]byte {
+			return viktor.Ptr([]byte(strconv.Itoa(*key)))
+		},
+		KeyDeserializer: func(_ context.Context, data *[]byte) *int {
+			key, err := strconv.Atoi(string(*data))
+			if err != nil {
+				return nil
+			}
+			return &key
+		},
+	}
+	c := NewLoadingCache[int, string](
+		WithExpireAfterWrite[int, string](time.Millisecond*100),
+		WithSnapshotCodec[int, string](codec),
+	)
+	c.Put(ctx, 1, viktor.Ptr("one"))
+	time.Sleep(time.Millisecond * 60)
+	c.Put(ctx, 2, viktor.Ptr("two"))
+
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	if err := SaveToFile(c, path); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewLoadingCache[int, string](
+		WithExpireAfterWrite[int, string](time.Millisecond*100),
+		WithSnapshotCodec[int, string](codec),
+	)
+	if err := LoadFromFile(restored, path); err != nil {
+		t.Fatal(err)
+	}
+	if val, ok := restored.Peek(ctx, 2); !ok || *val != "two" {
+		t.Fatalf("2=%v, want two", val)
+	}
+	// 剩余过期时间被保留, key 1 先过期
+	time.Sleep(time.Millisecond * 50)
+	if restored.Contains(ctx, 1) || !restored.Contains(ctx, 2) {
+		t.Fatalf("remaining ttl not restored, keys=%v", restored.Keys())
+	}
+}
+
+func TestStartPeriodicSnapshot(t *testing.T) {
+	cache := NewLRUCache(WithCapacity[string, int](10))
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	stop := StartPeriodicSnapshot(cache, path, time.Hour, nil)
+	cache.Put("a", viktor.Ptr(1))
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewLRUCache(WithCapacity[string, int](10))
+	if err := LoadFromFile(restored, path); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Contains("a") {
+		t.Fatal("final snapshot not saved on stop")
+	}
+}
@@ -0,0 +1,312 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec 声明式的缓存配置, 可以从配置文件中的JSON对象或者 ParseSpec 的字符串得到
+// 指针字段为nil表示使用默认值, 见 NewDefaultConf
+type Spec struct {
+	MaximumSize       *int      `json:"maximumSize,omitempty"`       // 最大容量
+	ExpireAfterWrite  *Duration `json:"expireAfterWrite,omitempty"`  // 过期时间
+	RefreshAfterWrite Duration  `json:"refreshAfterWrite,omitempty"` // 见 WithRefreshAfterWrite
+	ClearInterval     *Duration `json:"clearInterval,omitempty"`     // 定时清理过期key的间隔, 0表示不定时清理
+	MinClearInterval  *Duration `json:"minClearInterval,omitempty"`  // 见 WithMinClearInterval
+	ExpireJitter      float64   `json:"expireJitter,omitempty"`      // 见 WithExpireJitter
+	EarlyRefresh      float64   `json:"earlyRefresh,omitempty"`      // 见 WithEarlyRefresh
+	MaxPinnedRatio    *float64  `json:"maxPinnedRatio,omitempty"`    // 见 WithMaxPinnedRatio
+	RecordStats       bool      `json:"recordStats,omitempty"`       // 见 WithRecordStats
+	PrefixIndex       bool      `json:"prefixIndex,omitempty"`       // 见 WithPrefixIndex
+}
+
+// Duration JSON中以 "5m", "1h30m", "7d" 这样的字符串表示的时间间隔, 也接受以纳秒为单位的数字
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err = json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("%w: duration must be a string or an integer", ErrorInvalidSpec)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	parsed, err := parseSpecDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// parseSpecDuration 在 time.ParseDuration 的基础上支持以天为单位, 如 "7d"
+func parseSpecDuration(s string) (Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid duration %q", ErrorInvalidSpec, s)
+		}
+		return Duration(time.Duration(n) * time.Hour * 24), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid duration %q", ErrorInvalidSpec, s)
+	}
+	return Duration(d), nil
+}
+
+// ParseSpec 解析逗号分隔的配置字符串, 如 "maximumSize=1000,expireAfterWrite=5m,refreshAfterWrite=1m,recordStats"
+// 布尔配置只写名字; 未知的配置, 重复的配置以及非法的值都会返回 ErrorInvalidSpec
+func ParseSpec(s string) (*Spec, error) {
+	spec := &Spec{}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(part, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s was already set", ErrorInvalidSpec, name)
+		}
+		seen[name] = true
+		if err := spec.set(name, value, hasValue); err != nil {
+			return nil, err
+		}
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func (s *Spec) set(name, value string, hasValue bool) error {
+	switch name {
+	case "recordStats", "prefixIndex":
+		if hasValue {
+			return fmt.Errorf("%w: %s does not take a value", ErrorInvalidSpec, name)
+		}
+		if name == "recordStats" {
+			s.RecordStats = true
+		} else {
+			s.PrefixIndex = true
+		}
+		return nil
+	}
+	if !hasValue || value == "" {
+		return fmt.Errorf("%w: %s requires a value", ErrorInvalidSpec, name)
+	}
+	switch name {
+	case "maximumSize":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%w: invalid maximumSize %q", ErrorInvalidSpec, value)
+		}
+		s.MaximumSize = &n
+	case "expireAfterWrite", "refreshAfterWrite", "clearInterval", "minClearInterval":
+		d, err := parseSpecDuration(value)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "expireAfterWrite":
+			s.ExpireAfterWrite = &d
+		case "refreshAfterWrite":
+			s.RefreshAfterWrite = d
+		case "clearInterval":
+			s.ClearInterval = &d
+		default:
+			s.MinClearInterval = &d
+		}
+	case "expireJitter", "earlyRefresh", "maxPinnedRatio":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid %s %q", ErrorInvalidSpec, name, value)
+		}
+		switch name {
+		case "expireJitter":
+			s.ExpireJitter = f
+		case "earlyRefresh":
+			s.EarlyRefresh = f
+		default:
+			s.MaxPinnedRatio = &f
+		}
+	default:
+		return fmt.Errorf("%w: unknown option %s", ErrorInvalidSpec, name)
+	}
+	return nil
+}
+
+// UnmarshalJSON 既可以是JSON对象, 也可以是 ParseSpec 格式的字符串
+func (s *Spec) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		spec, err := ParseSpec(str)
+		if err != nil {
+			return err
+		}
+		*s = *spec
+		return nil
+	}
+	type plain Spec
+	var spec plain
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	if err := (*Spec)(&spec).Validate(); err != nil {
+		return err
+	}
+	*s = Spec(spec)
+	return nil
+}
+
+// String 转换成 ParseSpec 格式的字符串, 只包含设置过的配置
+func (s *Spec) String() string {
+	var parts []string
+	add := func(name string, value any) {
+		parts = append(parts, fmt.Sprintf("%s=%v", name, value))
+	}
+	if s.MaximumSize != nil {
+		add("maximumSize", *s.MaximumSize)
+	}
+	if s.ExpireAfterWrite != nil {
+		add("expireAfterWrite", *s.ExpireAfterWrite)
+	}
+	if s.RefreshAfterWrite != 0 {
+		add("refreshAfterWrite", s.RefreshAfterWrite)
+	}
+	if s.ClearInterval != nil {
+		add("clearInterval", *s.ClearInterval)
+	}
+	if s.MinClearInterval != nil {
+		add("minClearInterval", *s.MinClearInterval)
+	}
+	if s.ExpireJitter != 0 {
+		add("expireJitter", s.ExpireJitter)
+	}
+	if s.EarlyRefresh != 0 {
+		add("earlyRefresh", s.EarlyRefresh)
+	}
+	if s.MaxPinnedRatio != nil {
+		add("maxPinnedRatio", *s.MaxPinnedRatio)
+	}
+	if s.RecordStats {
+		parts = append(parts, "recordStats")
+	}
+	if s.PrefixIndex {
+		parts = append(parts, "prefixIndex")
+	}
+	return strings.Join(parts, ",")
+}
+
+// Validate 检查配置的取值范围, 与对应的 With* 方法会panic的情况一致
+func (s *Spec) Validate() error {
+	if s.MaximumSize != nil && *s.MaximumSize < 0 {
+		return fmt.Errorf("%w: maximumSize less than 0", ErrorInvalidSpec)
+	}
+	if s.ExpireAfterWrite != nil && *s.ExpireAfterWrite < 0 {
+		return fmt.Errorf("%w: expireAfterWrite less than 0", ErrorInvalidSpec)
+	}
+	if s.RefreshAfterWrite < 0 {
+		return fmt.Errorf("%w: refreshAfterWrite less than 0", ErrorInvalidSpec)
+	}
+	if s.RefreshAfterWrite > 0 && s.ExpireAfterWrite != nil && s.RefreshAfterWrite >= *s.ExpireAfterWrite {
+		return fmt.Errorf("%w: refreshAfterWrite must be less than expireAfterWrite", ErrorInvalidSpec)
+	}
+	if s.ClearInterval != nil && *s.ClearInterval < 0 {
+		return fmt.Errorf("%w: clearInterval less than 0", ErrorInvalidSpec)
+	}
+	if s.MinClearInterval != nil && *s.MinClearInterval < 0 {
+		return fmt.Errorf("%w: minClearInterval less than 0", ErrorInvalidSpec)
+	}
+	if s.ExpireJitter < 0 || s.ExpireJitter >= 1 {
+		return fmt.Errorf("%w: expireJitter must be in [0, 1)", ErrorInvalidSpec)
+	}
+	if s.EarlyRefresh < 0 {
+		return fmt.Errorf("%w: earlyRefresh less than 0", ErrorInvalidSpec)
+	}
+	if s.MaxPinnedRatio != nil && (*s.MaxPinnedRatio < 0 || *s.MaxPinnedRatio > 1) {
+		return fmt.Errorf("%w: maxPinnedRatio must be in [0, 1]", ErrorInvalidSpec)
+	}
+	return nil
+}
+
+// SpecOptions 校验spec并转换成 Option, 可以与其他 Option 一起传给任意缓存的构造方法, 如 WithPartitionCacheOptions
+func SpecOptions[K, V any](spec *Spec) ([]Option[K, V], error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	var opts []Option[K, V]
+	if spec.MaximumSize != nil {
+		opts = append(opts, WithCapacity[K, V](*spec.MaximumSize))
+	}
+	if spec.ExpireAfterWrite != nil {
+		opts = append(opts, WithExpireAfterWrite[K, V](time.Duration(*spec.ExpireAfterWrite)))
+	}
+	if spec.RefreshAfterWrite > 0 {
+		opts = append(opts, WithRefreshAfterWrite[K, V](time.Duration(spec.RefreshAfterWrite)))
+	}
+	if spec.ClearInterval != nil {
+		opts = append(opts, WithClearInterval[K, V](time.Duration(*spec.ClearInterval)))
+	}
+	if spec.MinClearInterval != nil {
+		opts = append(opts, WithMinClearInterval[K, V](time.Duration(*spec.MinClearInterval)))
+	}
+	if spec.ExpireJitter > 0 {
+		opts = append(opts, WithExpireJitter[K, V](spec.ExpireJitter))
+	}
+	if spec.EarlyRefresh > 0 {
+		opts = append(opts, WithEarlyRefresh[K, V](spec.EarlyRefresh))
+	}
+	if spec.MaxPinnedRatio != nil {
+		opts = append(opts, WithMaxPinnedRatio[K, V](*spec.MaxPinnedRatio))
+	}
+	if spec.RecordStats {
+		opts = append(opts, WithRecordStats[K, V]())
+	}
+	if spec.PrefixIndex {
+		opts = append(opts, WithPrefixIndex[K, V]())
+	}
+	return opts, nil
+}
+
+// NewLRUCacheFromSpec 按spec新建lru缓存, opts 在spec之后生效
+func NewLRUCacheFromSpec[K, V any](spec *Spec, opts ...Option[K, V]) (*LRUCache[K, V], error) {
+	specOpts, err := SpecOptions[K, V](spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewLRUCache(append(specOpts, opts...)...), nil
+}
+
+// NewLFUCacheFromSpec 按spec新建lfu缓存, opts 在spec之后生效
+func NewLFUCacheFromSpec[V any](spec *Spec, opts ...Option[any, V]) (*LFUCache[V], error) {
+	specOpts, err := SpecOptions[any, V](spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewLFUCacheWithOptions(append(specOpts, opts...)...), nil
+}
+
+// NewLoadingCacheFromSpec 按spec新建 LoadingCache, opts 在spec之后生效, 通常用来指定 WithGetterFunc 等无法写在配置中的选项
+func NewLoadingCacheFromSpec[K, V any](spec *Spec, opts ...Option[K, V]) (*LoadingCache[K, V], error) {
+	specOpts, err := SpecOptions[K, V](spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewLoadingCache(append(specOpts, opts...)...), nil
+}
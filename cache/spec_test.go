@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec("maximumSize=1000, expireAfterWrite=5m,refreshAfterWrite=1m,clearInterval=1d,recordStats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *spec.MaximumSize != 1000 || *spec.ExpireAfterWrite != Duration(5*time.Minute) ||
+		spec.RefreshAfterWrite != Duration(time.Minute) || *spec.ClearInterval != Duration(24*time.Hour) || !spec.RecordStats {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+	if s := spec.String(); s != "maximumSize=1000,expireAfterWrite=5m0s,refreshAfterWrite=1m0s,clearInterval=24h0m0s,recordStats" {
+		t.Fatal(s)
+	}
+
+	for _, s := range []string{
+		"maximumSize=-1",
+		"maximumSize",
+		"maximumSize=1,maximumSize=2",
+		"expireAfterWrite=5",
+		"expireAfterWrite=1m,refreshAfterWrite=1m",
+		"expireJitter=1",
+		"recordStats=true",
+		"unknown=1",
+	} {
+		if _, err := ParseSpec(s); !errors.Is(err, ErrorInvalidSpec) {
+			t.Errorf("%q: want ErrorInvalidSpec, got %v", s, err)
+		}
+	}
+}
+
+func TestSpecJSON(t *testing.T) {
+	var conf struct {
+		Users  Spec `json:"users"`
+		Orders Spec `json:"orders"`
+	}
+	data := `{"users": {"maximumSize": 10, "expireAfterWrite": "30s", "recordStats": true}, "orders": "maximumSize=20,prefixIndex"}`
+	if err := json.Unmarshal([]byte(data), &conf); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewLoadingCacheFromSpec[string, int](&conf.Users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Capacity() != 10 || c.conf.expireAfterWrite != 30*time.Second || !c.conf.recordStats {
+		t.Fatalf("unexpected config %+v", c.conf)
+	}
+	lru, err := NewLRUCacheFromSpec[string, int](&conf.Orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lru.Capacity() != 20 || !lru.conf.prefixIndex {
+		t.Fatalf("unexpected config %+v", lru.conf)
+	}
+
+	encoded, err := json.Marshal(conf.Users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"maximumSize":10,"expireAfterWrite":"30s","recordStats":true}` {
+		t.Fatal(string(encoded))
+	}
+
+	if err = json.Unmarshal([]byte(`{"users": {"maximumSize": -1}}`), &conf); !errors.Is(err, ErrorInvalidSpec) {
+		t.Fatalf("want ErrorInvalidSpec, got %v", err)
+	}
+	if _, err = NewLFUCacheFromSpec[int](&Spec{ExpireJitter: 2}); !errors.Is(err, ErrorInvalidSpec) {
+		t.Fatalf("want ErrorInvalidSpec, got %v", err)
+	}
+}
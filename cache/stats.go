@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats 缓存的统计数据, 需要通过 WithRecordStats 开启
+type Stats struct {
+	Hits          int64         `json:"hits"`
+	Misses        int64         `json:"misses"`
+	Loads         int64         `json:"loads"`
+	LoadErrors    int64         `json:"loadErrors"`
+	Evictions     int64         `json:"evictions"`     // 因容量不足被淘汰的数量
+	PinRejections int64         `json:"pinRejections"` // 超过 WithMaxPinnedRatio 的限制, 按普通元素写入的 Pinned 数量
+	TotalLoadTime time.Duration `json:"totalLoadTime"`
+}
+
+// HitRate 命中率, 没有请求时返回1
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 1
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// AverageLoadTime 平均加载耗时
+func (s Stats) AverageLoadTime() time.Duration {
+	if s.Loads+s.LoadErrors == 0 {
+		return 0
+	}
+	return s.TotalLoadTime / time.Duration(s.Loads+s.LoadErrors)
+}
+
+// statsCounter 并发安全的统计计数器, 未开启时不记录
+// int64字段放在最前面, 保证32位平台上原子操作的对齐
+type statsCounter struct {
+	hits       int64
+	misses     int64
+	loads      int64
+	loadErrors int64
+	evictions  int64
+	pinRejects int64
+	loadTime   int64
+	enabled    bool
+}
+
+func newStatsCounter(enabled bool) *statsCounter {
+	return &statsCounter{enabled: enabled}
+}
+
+func (s *statsCounter) recordHit() {
+	if s.enabled {
+		atomic.AddInt64(&s.hits, 1)
+	}
+}
+
+func (s *statsCounter) recordMiss() {
+	if s.enabled {
+		atomic.AddInt64(&s.misses, 1)
+	}
+}
+
+func (s *statsCounter) recordLoad(cost time.Duration, err error) {
+	if !s.enabled {
+		return
+	}
+	if err != nil {
+		atomic.AddInt64(&s.loadErrors, 1)
+	} else {
+		atomic.AddInt64(&s.loads, 1)
+	}
+	atomic.AddInt64(&s.loadTime, int64(cost))
+}
+
+func (s *statsCounter) recordEviction() {
+	if s.enabled {
+		atomic.AddInt64(&s.evictions, 1)
+	}
+}
+
+func (s *statsCounter) recordPinRejection() {
+	if s.enabled {
+		atomic.AddInt64(&s.pinRejects, 1)
+	}
+}
+
+func (s *statsCounter) snapshot() Stats {
+	return Stats{
+		Hits:          atomic.LoadInt64(&s.hits),
+		Misses:        atomic.LoadInt64(&s.misses),
+		Loads:         atomic.LoadInt64(&s.loads),
+		LoadErrors:    atomic.LoadInt64(&s.loadErrors),
+		Evictions:     atomic.LoadInt64(&s.evictions),
+		PinRejections: atomic.LoadInt64(&s.pinRejects),
+		TotalLoadTime: time.Duration(atomic.LoadInt64(&s.loadTime)),
+	}
+}
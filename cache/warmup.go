@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/myron934/go-viktor/concurrency"
+)
+
+// warmUpMaxBatchSize 使用批量加载预热时, 单次调用 loadFunc 的最大key数量
+const warmUpMaxBatchSize = 100
+
+// WarmUpFailure 预热失败的key
+type WarmUpFailure[K any] struct {
+	Key K
+	Err error
+}
+
+// WarmUpReport 预热结果
+type WarmUpReport[K any] struct {
+	Loaded  []K                // 成功加载的key
+	Skipped []K                // 已经在缓存中且未过期, 无需加载的key
+	Failed  []WarmUpFailure[K] // 加载失败的key
+	Cost    time.Duration      // 预热耗时
+}
+
+// WarmUp 通过加载方法预热keys, 配置了 WithLoadFunc 时使用批量加载, 否则逐个调用 getterFunc
+// 最多同时有parallelism个协程在加载, parallelism<=0时按1处理
+// ctx取消后尚未加载的key记为失败, 此时同时返回ctx.Err()
+func (c *LoadingCache[K, V]) WarmUp(ctx context.Context, keys []K, parallelism int) (*WarmUpReport[K], error) {
+	start := time.Now()
+	if c.conf.getterFunc == nil && c.conf.loadFunc == nil {
+		return nil, ErrorNoLoader
+	}
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	report := &WarmUpReport[K]{}
+	pending := make([]K, 0, len(keys))
+	for _, key := range keys {
+		if c.Contains(ctx, key) {
+			report.Skipped = append(report.Skipped, key)
+			continue
+		}
+		pending = append(pending, key)
+	}
+
+	batchSize := 1
+	if c.conf.loadFunc != nil {
+		batchSize = (len(pending) + parallelism - 1) / parallelism
+		if batchSize > warmUpMaxBatchSize {
+			batchSize = warmUpMaxBatchSize
+		}
+		if batchSize < 1 {
+			batchSize = 1
+		}
+	}
+	batches := make(chan []K)
+	workers := (len(pending) + batchSize - 1) / batchSize
+	if workers > parallelism {
+		workers = parallelism
+	}
+	futures := make([]*concurrency.Future[WarmUpReport[K]], 0, workers)
+	for i := 0; i < workers; i++ {
+		futures = append(futures, concurrency.Submit(func() (*WarmUpReport[K], error) {
+			part := &WarmUpReport[K]{}
+			for batch := range batches {
+				c.warmUpBatch(ctx, batch, part)
+			}
+			return part, nil
+		}))
+	}
+	for begin := 0; begin < len(pending); begin += batchSize {
+		end := begin + batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batches <- pending[begin:end]
+	}
+	close(batches)
+
+	for _, future := range futures {
+		part, _ := future.Get()
+		report.Loaded = append(report.Loaded, part.Loaded...)
+		report.Failed = append(report.Failed, part.Failed...)
+	}
+	report.Cost = time.Since(start)
+	return report, ctx.Err()
+}
+
+// warmUpBatch 加载一批key, 结果记录到report中
+func (c *LoadingCache[K, V]) warmUpBatch(ctx context.Context, batch []K, report *WarmUpReport[K]) {
+	if err := ctx.Err(); err != nil {
+		for _, key := range batch {
+			report.Failed = append(report.Failed, WarmUpFailure[K]{Key: key, Err: err})
+		}
+		return
+	}
+	if c.conf.loadFunc == nil {
+		for _, key := range batch {
+			if _, err := c.refresh(key); err != nil {
+				report.Failed = append(report.Failed, WarmUpFailure[K]{Key: key, Err: err})
+				continue
+			}
+			report.Loaded = append(report.Loaded, key)
+		}
+		return
+	}
+
+	keys := make([]any, 0, len(batch))
+	for _, key := range batch {
+		keys = append(keys, key)
+	}
+	result, err := c.conf.loadFunc(ctx, keys)
+	if err != nil {
+		for _, key := range batch {
+			report.Failed = append(report.Failed, WarmUpFailure[K]{Key: key, Err: err})
+		}
+		return
+	}
+	values := make(map[string]*V, len(result))
+	for key, val := range result {
+		values[c.stringKey(key)] = val
+	}
+	c.mutex.Lock()
+	defer c.unlock()
+	for _, key := range batch {
+		val, ok := values[c.stringKey(key)]
+		if !ok {
+			report.Failed = append(report.Failed, WarmUpFailure[K]{Key: key, Err: ErrorKeyNotFound})
+			continue
+		}
+		if err = c.put(key, val, fromLoad(0)); err != nil {
+			report.Failed = append(report.Failed, WarmUpFailure[K]{Key: key, Err: err})
+			continue
+		}
+		report.Loaded = append(report.Loaded, key)
+	}
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+)
+
+func TestLoadingCacheWarmUp(t *testing.T) {
+	ctx := context.Background()
+	var running, maxRunning int32
+	c := NewLoadingCache[int, int](
+		WithCapacity[int, int](100),
+		WithGetterFunc[int, int](func(key int) (*int, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond * 10)
+			if key < 0 {
+				return nil, fmt.Errorf("bad key %d", key)
+			}
+			return viktor.Ptr(key * 10), nil
+		}),
+	)
+	c.Put(ctx, 0, viktor.Ptr(0))
+	keys := []int{0, 1, 2, 3, 4, 5, 6, 7, -1}
+	report, err := c.WarmUp(ctx, keys, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Loaded) != 7 || len(report.Skipped) != 1 || len(report.Failed) != 1 {
+		t.Fatalf("loaded=%v, skipped=%v, failed=%v", report.Loaded, report.Skipped, report.Failed)
+	}
+	if report.Failed[0].Key != -1 {
+		t.Fatalf("failed key=%v, want -1", report.Failed[0].Key)
+	}
+	if maxRunning > 3 || maxRunning < 2 {
+		t.Fatalf("max parallel loads=%d, want 2..3", maxRunning)
+	}
+	if val, ok := c.Peek(ctx, 7); !ok || *val != 70 {
+		t.Fatalf("7=%v, want 70", val)
+	}
+}
+
+func TestLoadingCacheWarmUpBatch(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	c := NewLoadingCache[string, string](
+		WithLoadFunc[string, string](func(_ context.Context, keys []any) (map[any]*string, error) {
+			atomic.AddInt32(&calls, 1)
+			result := make(map[any]*string)
+			for _, key := range keys {
+				if key.(string) != "missing" {
+					result[key] = viktor.Ptr("v-" + key.(string))
+				}
+			}
+			return result, nil
+		}),
+	)
+	report, err := c.WarmUp(ctx, []string{"a", "b", "c", "missing"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Loaded) != 3 || len(report.Failed) != 1 || !errors.Is(report.Failed[0].Err, ErrorKeyNotFound) {
+		t.Fatalf("loaded=%v, failed=%v", report.Loaded, report.Failed)
+	}
+	if calls != 2 {
+		t.Fatalf("loadFunc calls=%d, want 2", calls)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	report, err = c.WarmUp(cancelled, []string{"d", "e"}, 2)
+	if !errors.Is(err, context.Canceled) || len(report.Failed) != 2 {
+		t.Fatalf("err=%v, failed=%v", err, report.Failed)
+	}
+
+	if _, err = NewLoadingCache[string, string]().WarmUp(ctx, []string{"a"}, 1); !errors.Is(err, ErrorNoLoader) {
+		t.Fatalf("err=%v, want ErrorNoLoader", err)
+	}
+}
+
+func TestLoadingCacheLoadDeduplicated(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	c := NewLoadingCache[string, int](
+		WithGetterFunc[string, int](func(key string) (*int, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(time.Millisecond * 20)
+			return viktor.Ptr(1), nil
+		}),
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if val := c.MustGet(ctx, "a"); val == nil || *val != 1 {
+				t.Errorf("val=%v, want 1", val)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("getter calls=%d, want 1", calls)
+	}
+}
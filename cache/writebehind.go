@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WriterFunc 批量写回函数, entries的key为写入时的原始key, 见 WithWriteBehind
+type WriterFunc[V any] func(ctx context.Context, entries map[any]*V) error
+
+type writeBehindConf[V any] struct {
+	writer     WriterFunc[V]
+	interval   time.Duration
+	batchSize  int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+type dirtyEntry[K, V any] struct {
+	key   K
+	value *V
+}
+
+// writeBehind 脏数据以及后台写回任务
+type writeBehind[K, V any] struct {
+	conf     *writeBehindConf[V]
+	clock    Clock
+	mutex    sync.Mutex
+	dirty    map[string]dirtyEntry[K, V]
+	inflight map[string]dirtyEntry[K, V] // 正在写回的数据, 写回完成前仍然可以被读到
+	removed  map[string]bool             // 写回期间被删除的key, 不再从inflight读到, 写回失败也不重试
+	writes   int                         // 上次写回之后的写入次数
+	backoff  time.Duration               // 当前的重试间隔, 0表示上次写回成功
+	timer    Timer
+
+	flushMutex sync.Mutex // 保证同一时刻只有一个写回
+	kick       chan struct{}
+	stop       chan struct{}
+	done       chan struct{}
+	stopOnce   sync.Once
+}
+
+func newWriteBehind[K, V any](conf *writeBehindConf[V], clock Clock) *writeBehind[K, V] {
+	w := &writeBehind[K, V]{
+		conf:  conf,
+		clock: clock,
+		dirty: make(map[string]dirtyEntry[K, V]),
+		kick:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	w.schedule(nil)
+	go w.run()
+	return w
+}
+
+// markDirty 标记脏数据, 写入次数达到batchSize时触发写回, 重试期间不触发
+func (w *writeBehind[K, V]) markDirty(strKey string, key K, value *V) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.dirty[strKey] = dirtyEntry[K, V]{key: key, value: value}
+	w.writes++
+	if w.conf.batchSize > 0 && w.writes >= w.conf.batchSize && w.backoff == 0 {
+		w.signal()
+	}
+}
+
+// flushIfDirty key是脏数据时触发写回, 用于元素被淘汰或过期时
+func (w *writeBehind[K, V]) flushIfDirty(strKey string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if _, ok := w.dirty[strKey]; ok {
+		w.signal()
+	}
+}
+
+// lookup 获取还没有写回的值
+func (w *writeBehind[K, V]) lookup(strKey string) (*V, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if entry, ok := w.dirty[strKey]; ok {
+		return entry.value, true
+	}
+	if entry, ok := w.inflight[strKey]; ok && !w.removed[strKey] {
+		return entry.value, true
+	}
+	return nil, false
+}
+
+// forget 丢弃key还没有写回的值, 用于key从缓存删除时; 已经开始写回的值无法撤回, 但不再被读到, 写回失败也不重试
+func (w *writeBehind[K, V]) forget(strKey string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.forgetLocked(strKey)
+}
+
+// forgetIf 对满足match的key执行 forget
+func (w *writeBehind[K, V]) forgetIf(match func(strKey string) bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for strKey := range w.dirty {
+		if match(strKey) {
+			delete(w.dirty, strKey)
+		}
+	}
+	for strKey := range w.inflight {
+		if match(strKey) {
+			w.forgetLocked(strKey)
+		}
+	}
+}
+
+func (w *writeBehind[K, V]) forgetLocked(strKey string) {
+	delete(w.dirty, strKey)
+	if _, ok := w.inflight[strKey]; ok {
+		if w.removed == nil {
+			w.removed = make(map[string]bool)
+		}
+		w.removed[strKey] = true
+	}
+}
+
+func (w *writeBehind[K, V]) signal() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (w *writeBehind[K, V]) run() {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.kick:
+		}
+		w.schedule(w.flush(context.Background()))
+	}
+}
+
+// schedule 安排下一次写回, 上次写回失败时按退避间隔重试
+func (w *writeBehind[K, V]) schedule(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delay := w.conf.interval
+	if err == nil {
+		w.backoff = 0
+	} else {
+		if w.backoff == 0 {
+			w.backoff = w.conf.minBackoff
+		} else if w.backoff *= 2; w.backoff > w.conf.maxBackoff {
+			w.backoff = w.conf.maxBackoff
+		}
+		delay = w.backoff
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = w.clock.AfterFunc(delay, w.signal)
+}
+
+// flush 写回当前全部的脏数据, 写回失败的数据如果期间没有被再次写入, 会重新标记为脏数据
+func (w *writeBehind[K, V]) flush(ctx context.Context) error {
+	w.flushMutex.Lock()
+	defer w.flushMutex.Unlock()
+
+	w.mutex.Lock()
+	batch := w.dirty
+	w.dirty = make(map[string]dirtyEntry[K, V])
+	w.writes = 0
+	w.inflight = batch
+	w.mutex.Unlock()
+
+	var firstErr error
+	failed := make(map[string]dirtyEntry[K, V])
+	chunk := make(map[any]*V)
+	chunkKeys := make([]string, 0)
+	write := func() {
+		if len(chunk) == 0 {
+			return
+		}
+		if err := w.conf.writer(ctx, chunk); err != nil {
+			for _, strKey := range chunkKeys {
+				failed[strKey] = batch[strKey]
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		chunk = make(map[any]*V)
+		chunkKeys = chunkKeys[:0]
+	}
+	for strKey, entry := range batch {
+		chunk[entry.key] = entry.value
+		chunkKeys = append(chunkKeys, strKey)
+		if w.conf.batchSize > 0 && len(chunk) >= w.conf.batchSize {
+			write()
+		}
+	}
+	write()
+
+	w.mutex.Lock()
+	for strKey, entry := range failed {
+		if _, newer := w.dirty[strKey]; !newer && !w.removed[strKey] {
+			w.dirty[strKey] = entry
+		}
+	}
+	w.inflight = nil
+	w.removed = nil
+	w.mutex.Unlock()
+	return firstErr
+}
+
+// close 停止后台任务并写回剩余的脏数据
+func (w *writeBehind[K, V]) close(ctx context.Context) error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	w.mutex.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.mutex.Unlock()
+	return w.flush(ctx)
+}
+
+// Flush 立即写回全部脏数据, 未开启 WithWriteBehind 时什么也不做
+func (c *LoadingCache[K, V]) Flush(ctx context.Context) error {
+	if c.writeBehind == nil {
+		return nil
+	}
+	return c.writeBehind.flush(ctx)
+}
+
+// pendingWrite 获取还没有写回的值
+func (c *LoadingCache[K, V]) pendingWrite(key K) (*V, bool) {
+	if c.writeBehind == nil {
+		return nil, false
+	}
+	return c.writeBehind.lookup(c.stringKey(key))
+}
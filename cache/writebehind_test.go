@@ -0,0 +1,227 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+	"github.com/myron934/go-viktor/cache"
+	"github.com/myron934/go-viktor/cache/cachetest"
+)
+
+// memorySink 记录写回结果的存储, 前failures次写回失败
+type memorySink struct {
+	mutex    sync.Mutex
+	data     map[string]int
+	attempts int
+	failures int
+}
+
+func (s *memorySink) write(_ context.Context, entries map[any]*int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errors.New("sink unavailable")
+	}
+	for key, val := range entries {
+		s.data[key.(string)] = *val
+	}
+	return nil
+}
+
+func (s *memorySink) get(key string) (int, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	val, ok := s.data[key]
+	return val, ok
+}
+
+func (s *memorySink) attemptCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.attempts
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	sink := &memorySink{data: make(map[string]int)}
+	c := cache.NewLoadingCache(
+		cache.WithClock[string, int](clock),
+		cache.WithWriteBehind[string, int](sink.write, time.Second, 3),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			return viktor.Ptr(-1), nil
+		}),
+	)
+	defer c.Close(ctx)
+
+	c.Put(ctx, "a", viktor.Ptr(1))
+	c.Put(ctx, "a", viktor.Ptr(2))
+	if sink.attemptCount() != 0 {
+		t.Fatal("written before the interval")
+	}
+	clock.Advance(time.Second)
+	waitFor(t, "interval flush", func() bool { v, _ := sink.get("a"); return v == 2 })
+
+	// 累计3次写入立即写回
+	for _, key := range []string{"b", "c", "d"} {
+		c.Put(ctx, key, viktor.Ptr(3))
+	}
+	waitFor(t, "batch flush", func() bool { _, ok := sink.get("d"); return ok })
+
+	// 加载的数据不会写回
+	c.Get(ctx, "loaded")
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sink.get("loaded"); ok {
+		t.Fatal("loaded value should not be written back")
+	}
+
+	c.Put(ctx, "e", viktor.Ptr(5))
+	if err := c.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := sink.get("e"); v != 5 {
+		t.Fatal("Close should flush pending writes")
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	ctx := context.Background()
+	clock := cachetest.NewFakeClock(time.Now())
+	sink := &memorySink{data: make(map[string]int), failures: 2}
+	c := cache.NewLoadingCache(
+		cache.WithClock[string, int](clock),
+		cache.WithWriteBehind[string, int](sink.write, time.Minute, 0),
+		cache.WithWriteBehindBackoff[string, int](time.Second, 10*time.Second),
+		cache.WithClearInterval[string, int](0),
+	)
+	defer c.Close(ctx)
+
+	c.Put(ctx, "a", viktor.Ptr(1))
+	clock.Advance(time.Minute)
+	waitFor(t, "first attempt", func() bool { return sink.attemptCount() == 1 })
+	// 写回失败期间的新值不会被旧值覆盖
+	c.Put(ctx, "a", viktor.Ptr(2))
+	waitFor(t, "retry scheduled", func() bool { return clock.Timers() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, "second attempt", func() bool { return sink.attemptCount() == 2 })
+	waitFor(t, "retry scheduled", func() bool { return clock.Timers() == 1 })
+	clock.Advance(time.Second)
+	if sink.attemptCount() != 2 {
+		t.Fatal("backoff should double after the second failure")
+	}
+	clock.Advance(time.Second)
+	waitFor(t, "successful retry", func() bool { v, _ := sink.get("a"); return v == 2 })
+}
+
+func TestWriteBehindEvict(t *testing.T) {
+	ctx := context.Background()
+	sink := &memorySink{data: make(map[string]int), failures: 1000}
+	c := cache.NewLoadingCache(
+		cache.WithCapacity[string, int](1),
+		cache.WithWriteBehind[string, int](sink.write, time.Hour, 0),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			return viktor.Ptr(-1), nil
+		}),
+	)
+	c.Put(ctx, "a", viktor.Ptr(1))
+	c.Put(ctx, "b", viktor.Ptr(2))
+	// 淘汰脏数据时立即触发写回
+	waitFor(t, "flush on evict", func() bool { return sink.attemptCount() > 0 })
+	// 还没有写回成功, 加载时返回未写回的值而不是存储中的旧值
+	if v := c.MustGet(ctx, "a"); *v != 1 {
+		t.Fatalf("got %d, want the pending write", *v)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := c.Close(ctx); err == nil {
+		t.Fatal("Close should report the failed flush")
+	}
+}
+
+func TestWriteBehindRemove(t *testing.T) {
+	ctx := context.Background()
+	sink := &memorySink{data: make(map[string]int)}
+	c := cache.NewLoadingCache(
+		cache.WithWriteBehind[string, int](sink.write, time.Hour, 0),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			return viktor.Ptr(-1), nil
+		}),
+	)
+	defer c.Close(ctx)
+
+	for _, key := range []string{"a", "b", "p/1", "p/2"} {
+		c.Put(ctx, key, viktor.Ptr(1))
+	}
+	c.Remove(ctx, "a")
+	c.InvalidatePrefix(ctx, "p/")
+	// 删除后不再读到未写回的值
+	if v := c.MustGet(ctx, "a"); *v != -1 {
+		t.Fatalf("got %d after Remove, want the loaded value", *v)
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "p/1", "p/2"} {
+		if _, ok := sink.get(key); ok {
+			t.Fatalf("removed key %s was written back", key)
+		}
+	}
+	if v, _ := sink.get("b"); v != 1 {
+		t.Fatal("b should be written back")
+	}
+}
+
+func TestWriteBehindRemoveDuringFlush(t *testing.T) {
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var attempts int
+	c := cache.NewLoadingCache(
+		cache.WithWriteBehind[string, int](func(ctx context.Context, entries map[any]*int) error {
+			if attempts++; attempts == 1 {
+				close(started)
+				<-release
+			}
+			return errors.New("sink unavailable")
+		}, time.Hour, 0),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			return viktor.Ptr(-1), nil
+		}),
+	)
+	c.Put(ctx, "a", viktor.Ptr(1))
+	c.Remove(ctx, "a")
+	c.Put(ctx, "a", viktor.Ptr(2))
+	flushed := make(chan error)
+	go func() { flushed <- c.Flush(ctx) }()
+	<-started
+	// 正在写回的值被删除后不再被读到, 写回失败也不会重试
+	c.Remove(ctx, "a")
+	if v := c.MustGet(ctx, "a"); *v != -1 {
+		t.Fatalf("got %d after Remove, want the loaded value", *v)
+	}
+	close(release)
+	if err := <-flushed; err == nil {
+		t.Fatal("Flush should report the failed write")
+	}
+	if err := c.Close(ctx); err != nil || attempts != 1 {
+		t.Fatalf("removed key retried: err=%v, attempts=%d", err, attempts)
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+// keyLockStripes 同步写入时按key分段加锁的段数
+const keyLockStripes = 64
+
+// lockKey 开启同步写入或删除时锁住key所在的段, 返回解锁方法; 未开启时什么也不做
+// 写入和删除持有段锁, 加载不持有, 因此 getterFunc 中可以读取缓存; 加锁顺序固定为先段锁后 c.mutex
+func (c *LoadingCache[K, V]) lockKey(key K) func() {
+	if c.conf.writer == nil && c.conf.deleter == nil {
+		return func() {}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(c.stringKey(key)))
+	mutex := &c.keyLocks[h.Sum32()%keyLockStripes]
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// writeThrough 写入存储, 未配置 WithWriter 时什么也不做
+func (c *LoadingCache[K, V]) writeThrough(ctx context.Context, key K, val *V) error {
+	if c.conf.writer == nil {
+		return nil
+	}
+	return c.conf.writer(ctx, key, val)
+}
+
+// writeThroughLocked 同 writeThrough, 调用方需持有key的段锁和 c.mutex
+// 写入存储期间释放 c.mutex, 慢的存储只会阻塞同一段的key; 同一个key的写入和删除被段锁串行化,
+// 释放期间key只可能被淘汰, 过期, 清空或者被加载写入, 之后的 put 会覆盖加载的值
+func (c *LoadingCache[K, V]) writeThroughLocked(ctx context.Context, key K, val *V) error {
+	if c.conf.writer == nil {
+		return nil
+	}
+	c.unlock()
+	defer c.mutex.Lock()
+	return c.conf.writer(ctx, key, val)
+}
+
+// deleteThroughLocked 从存储删除, 未配置 WithDeleter 时什么也不做; 加锁要求同 writeThroughLocked
+func (c *LoadingCache[K, V]) deleteThroughLocked(ctx context.Context, key K) error {
+	if c.conf.deleter == nil {
+		return nil
+	}
+	c.unlock()
+	defer c.mutex.Lock()
+	return c.conf.deleter(ctx, key)
+}
@@ -0,0 +1,211 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	viktor "github.com/myron934/go-viktor"
+	"github.com/myron934/go-viktor/cache"
+)
+
+// failingStore fail为true时写入和删除都返回错误
+type failingStore struct {
+	mutex sync.Mutex
+	data  map[string]int
+	fail  bool
+}
+
+var errStore = errors.New("store unavailable")
+
+func (s *failingStore) write(_ context.Context, key string, val *int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail {
+		return errStore
+	}
+	s.data[key] = *val
+	return nil
+}
+
+func (s *failingStore) delete(_ context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail {
+		return errStore
+	}
+	delete(s.data, key)
+	return nil
+}
+
+func (s *failingStore) setFail(fail bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fail = fail
+}
+
+func TestWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	store := &failingStore{data: make(map[string]int)}
+	c := cache.NewLoadingCache(
+		cache.WithWriter[string, int](store.write),
+		cache.WithDeleter[string, int](store.delete),
+	)
+	defer c.Close(ctx)
+
+	if err := c.Put(ctx, "a", viktor.Ptr(1)); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := c.Get(ctx, "a"); v == nil || *v != 1 || store.data["a"] != 1 {
+		t.Fatal("put should write to both store and cache")
+	}
+
+	// 存储失败时缓存保持不变
+	store.setFail(true)
+	if err := c.Put(ctx, "a", viktor.Ptr(2)); !errors.Is(err, errStore) {
+		t.Fatalf("want store error, got %v", err)
+	}
+	if v, _ := c.Get(ctx, "a"); *v != 1 {
+		t.Fatal("cache changed after failed write")
+	}
+	if _, _, err := c.Compute(ctx, "a", func(old *int, ok bool) (*int, bool) {
+		return viktor.Ptr(*old + 1), true
+	}); !errors.Is(err, errStore) {
+		t.Fatalf("want store error, got %v", err)
+	}
+	if err := c.Remove(ctx, "a"); !errors.Is(err, errStore) {
+		t.Fatalf("want store error, got %v", err)
+	}
+	if v, _ := c.Get(ctx, "a"); *v != 1 {
+		t.Fatal("cache changed after failed write")
+	}
+
+	store.setFail(false)
+	if _, _, err := c.Compute(ctx, "a", func(old *int, ok bool) (*int, bool) {
+		return viktor.Ptr(*old + 1), true
+	}); err != nil || store.data["a"] != 2 {
+		t.Fatal("compute should write through", err)
+	}
+	if err := c.Remove(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.data["a"]; ok || c.Size() != 0 {
+		t.Fatal("remove should delete from both store and cache")
+	}
+}
+
+func TestWriteThroughOutsideLock(t *testing.T) {
+	ctx := context.Background()
+	entered, release := make(chan struct{}), make(chan struct{})
+	c := cache.NewLoadingCache(
+		cache.WithWriter[string, int](func(_ context.Context, key string, _ *int) error {
+			if key == "slow" {
+				close(entered)
+				<-release
+			}
+			return nil
+		}),
+	)
+	defer c.Close(ctx)
+	c.Put(ctx, "other", viktor.Ptr(1))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Compute(ctx, "slow", func(*int, bool) (*int, bool) { return viktor.Ptr(2), true })
+	}()
+	<-entered
+	// 慢的writer不会阻塞其他key的读取
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		c.Get(ctx, "other")
+	}()
+	select {
+	case <-read:
+	case <-time.After(5 * time.Second):
+		t.Fatal("get blocked by a slow writer")
+	}
+	close(release)
+	<-done
+	if v, _ := c.Peek(ctx, "slow"); v == nil || *v != 2 {
+		t.Fatalf("slow=%v, want 2", v)
+	}
+}
+
+func TestWriteThroughDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	entered, release := make(chan struct{}), make(chan struct{})
+	c := cache.NewLoadingCache(
+		cache.WithWriter[string, int](func(context.Context, string, *int) error { return nil }),
+		cache.WithGetterFunc[string, int](func(string) (*int, error) {
+			close(entered)
+			<-release
+			return viktor.Ptr(1), nil
+		}),
+	)
+	defer c.Close(ctx)
+
+	loaded := make(chan *int)
+	go func() {
+		loaded <- c.MustGet(ctx, "k")
+	}()
+	<-entered
+	// 加载开始后的写入不等待加载, 也不会被加载的旧值覆盖
+	if err := c.Put(ctx, "k", viktor.Ptr(2)); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if v := <-loaded; v == nil || *v != 2 {
+		t.Fatalf("Get=%v, want the value written during the load", v)
+	}
+	if v, _ := c.Peek(ctx, "k"); v == nil || *v != 2 {
+		t.Fatalf("k=%v, want the value written during the load", v)
+	}
+}
+
+func TestWriteThroughLoaderReadsCache(t *testing.T) {
+	ctx := context.Background()
+	// 找一个与k在同一段(共64段, FNV-1a)的key
+	stripe := func(key string) uint32 {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return h.Sum32() % 64
+	}
+	other := 0
+	for stripe(strconv.Itoa(other)) != stripe("k") {
+		other++
+	}
+	var c *cache.LoadingCache[string, int]
+	c = cache.NewLoadingCache(
+		cache.WithWriter[string, int](func(context.Context, string, *int) error { return nil }),
+		cache.WithGetterFunc[string, int](func(key string) (*int, error) {
+			if key != "k" {
+				return viktor.Ptr(1), nil
+			}
+			// 加载时读取同一段的其他key不会死锁
+			return viktor.Ptr(*c.MustGet(ctx, strconv.Itoa(other)) + 1), nil
+		}),
+	)
+	defer c.Close(ctx)
+	if v := c.MustGet(ctx, "k"); v == nil || *v != 2 {
+		t.Fatalf("k=%v, want 2", v)
+	}
+}
+
+func TestWriteThroughWithWriteBehind(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("want panic")
+		}
+	}()
+	store := &failingStore{data: make(map[string]int)}
+	cache.NewLoadingCache(
+		cache.WithWriter[string, int](store.write),
+		cache.WithWriteBehind[string, int](func(context.Context, map[any]*int) error { return nil }, 1, 0),
+	)
+}
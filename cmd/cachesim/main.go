@@ -0,0 +1,130 @@
+// cachesim 回放访问记录, 比较不同淘汰策略在不同容量下的命中率
+//
+//	cachesim -policies lru,lfu,clock -capacities 1000,10000 trace.txt
+//	cat trace.csv | cachesim -format csv -output csv > curve.csv
+//
+// 访问记录为每行一个key, 或者 timestamp,key[,size] 格式的csv; 未命中时把key写入缓存
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "cachesim:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("cachesim", flag.ContinueOnError)
+	format := flags.String("format", formatAuto, "trace format: auto, plain or csv")
+	policyList := flags.String("policies", strings.Join(policyNames(), ","), "comma separated policies to compare")
+	capacityList := flags.String("capacities", "", "comma separated capacities, default is 1%..100% of the distinct keys")
+	output := flags.String("output", "table", "output format: table or csv")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	input := stdin
+	if path := flags.Arg(0); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	}
+	trace, err := readTrace(input, *format)
+	if err != nil {
+		return err
+	}
+	if len(trace) == 0 {
+		return fmt.Errorf("empty trace")
+	}
+
+	capacities := defaultCapacities(trace)
+	if *capacityList != "" {
+		if capacities, err = parseCapacities(*capacityList); err != nil {
+			return err
+		}
+	}
+	names := splitList(*policyList)
+	results, err := simulate(trace, names, capacities)
+	if err != nil {
+		return err
+	}
+	switch *output {
+	case "table":
+		return writeTable(stdout, names, capacities, results)
+	case "csv":
+		return writeCSV(stdout, results)
+	default:
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseCapacities(s string) ([]int, error) {
+	var capacities []int
+	for _, item := range splitList(s) {
+		capacity, err := strconv.Atoi(item)
+		if err != nil || capacity <= 0 {
+			return nil, fmt.Errorf("invalid capacity %q", item)
+		}
+		capacities = append(capacities, capacity)
+	}
+	return capacities, nil
+}
+
+// writeTable 每行一个容量, 每列一个策略的命中率
+func writeTable(w io.Writer, names []string, capacities []int, results []result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "capacity\t")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t", name)
+	}
+	fmt.Fprintln(tw)
+	for i, capacity := range capacities {
+		fmt.Fprintf(tw, "%d\t", capacity)
+		for j := range names {
+			fmt.Fprintf(tw, "%.2f%%\t", results[i*len(names)+j].hitRatio()*100)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, results []result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"policy", "capacity", "requests", "hits", "hit_ratio", "byte_hit_ratio"})
+	for _, r := range results {
+		cw.Write([]string{
+			r.policy,
+			strconv.Itoa(r.capacity),
+			strconv.FormatInt(r.requests, 10),
+			strconv.FormatInt(r.hits, 10),
+			strconv.FormatFloat(r.hitRatio(), 'f', 4, 64),
+			strconv.FormatFloat(r.byteHitRatio(), 'f', 4, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
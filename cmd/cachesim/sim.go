@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/myron934/go-viktor/cache"
+)
+
+// policy 被模拟的淘汰策略
+type policy interface {
+	// get 返回key是否命中
+	get(key string) bool
+	put(key string)
+}
+
+var present = &struct{}{}
+
+type lruPolicy struct {
+	c *cache.LRUCache[string, struct{}]
+}
+
+func (p lruPolicy) get(key string) bool { _, err := p.c.Get(key); return err == nil }
+func (p lruPolicy) put(key string)      { p.c.Put(key, present) }
+
+type lfuPolicy struct{ c *cache.LFUCache[struct{}] }
+
+func (p lfuPolicy) get(key string) bool { return p.c.Get(key) != nil }
+func (p lfuPolicy) put(key string)      { p.c.Put(key, present) }
+
+type clockPolicy struct {
+	c *cache.ClockCache[string, struct{}]
+}
+
+func (p clockPolicy) get(key string) bool { _, err := p.c.Get(key); return err == nil }
+func (p clockPolicy) put(key string)      { p.c.Put(key, present) }
+
+// policies 可以模拟的策略, 新增的策略在这里注册
+var policies = map[string]func(capacity int) policy{
+	"lru": func(capacity int) policy {
+		return lruPolicy{cache.NewLRUCache(cache.WithCapacity[string, struct{}](capacity))}
+	},
+	"lfu": func(capacity int) policy {
+		return lfuPolicy{cache.NewLFUCache[struct{}](capacity)}
+	},
+	"clock": func(capacity int) policy {
+		return clockPolicy{cache.NewClockCache(cache.WithCapacity[string, struct{}](capacity))}
+	},
+}
+
+func policyNames() []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// result 一个策略在一个容量下的回放结果
+type result struct {
+	policy   string
+	capacity int
+	requests int64
+	hits     int64
+	bytes    int64
+	hitBytes int64
+}
+
+func (r result) hitRatio() float64 {
+	if r.requests == 0 {
+		return 0
+	}
+	return float64(r.hits) / float64(r.requests)
+}
+
+func (r result) byteHitRatio() float64 {
+	if r.bytes == 0 {
+		return 0
+	}
+	return float64(r.hitBytes) / float64(r.bytes)
+}
+
+// replay 回放访问记录, 未命中时写入缓存
+func replay(trace []access, name string, capacity int) (result, error) {
+	newPolicy, ok := policies[name]
+	if !ok {
+		return result{}, fmt.Errorf("unknown policy %q", name)
+	}
+	p := newPolicy(capacity)
+	r := result{policy: name, capacity: capacity}
+	for _, a := range trace {
+		r.requests++
+		r.bytes += a.size
+		if p.get(a.key) {
+			r.hits++
+			r.hitBytes += a.size
+			continue
+		}
+		p.put(a.key)
+	}
+	return r, nil
+}
+
+// simulate 并发回放每个策略和容量的组合, 结果按容量, 策略排序
+func simulate(trace []access, names []string, capacities []int) ([]result, error) {
+	for _, name := range names {
+		if _, ok := policies[name]; !ok {
+			return nil, fmt.Errorf("unknown policy %q, available: %v", name, policyNames())
+		}
+	}
+	results := make([]result, len(names)*len(capacities))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for i, capacity := range capacities {
+		for j, name := range names {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(idx int, name string, capacity int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				// 策略名已经校验过, 不会出错
+				results[idx], _ = replay(trace, name, capacity)
+			}(i*len(names)+j, name, capacity)
+		}
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// defaultCapacities 没有指定容量时, 取不同key数量的 1%, 2%, 5%, 10%, 20%, 50%, 100%
+func defaultCapacities(trace []access) []int {
+	unique := make(map[string]struct{})
+	for _, a := range trace {
+		unique[a.key] = struct{}{}
+	}
+	var capacities []int
+	for _, percent := range []int{1, 2, 5, 10, 20, 50, 100} {
+		capacity := len(unique) * percent / 100
+		if capacity < 1 {
+			capacity = 1
+		}
+		if n := len(capacities); n == 0 || capacities[n-1] != capacity {
+			capacities = append(capacities, capacity)
+		}
+	}
+	return capacities
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadTrace(t *testing.T) {
+	plain, err := readTrace(strings.NewReader("a\n\nb\na\n"), formatAuto)
+	if err != nil || len(plain) != 3 || plain[2].key != "a" {
+		t.Fatal(plain, err)
+	}
+	csvTrace, err := readTrace(strings.NewReader("ts,key,size\n1,a,100\n2,b,20\n"), formatAuto)
+	if err != nil || len(csvTrace) != 2 || csvTrace[0].size != 100 {
+		t.Fatal(csvTrace, err)
+	}
+	// 只有两列的表头通过timestamp识别
+	twoColumns, err := readTrace(strings.NewReader("timestamp,key\n1,a\n2024-01-02T15:04:05Z,b\n"), formatAuto)
+	if err != nil || len(twoColumns) != 2 || twoColumns[0].key != "a" || twoColumns[1].key != "b" {
+		t.Fatal(twoColumns, err)
+	}
+	if _, err = readTrace(strings.NewReader("1,a,100\n2,b,x\n"), formatCSV); err == nil {
+		t.Fatal("invalid size should fail")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	// 循环访问3个key, 容量为2时lru全部未命中, 容量为3时只有首次访问未命中
+	trace, _ := readTrace(strings.NewReader(strings.Repeat("a\nb\nc\n", 10)), formatPlain)
+	results, err := simulate(trace, []string{"lru", "clock"}, []int{2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].hits != 0 || results[2].hits != 27 || results[3].hits != 27 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if _, err = simulate(trace, []string{"fifo"}, []int{1}); err == nil {
+		t.Fatal("unknown policy should fail")
+	}
+}
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"-capacities", "1,2", "-output", "csv"}, strings.NewReader("a\na\nb\n"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	// 表头 + 2个容量 * 3个策略
+	if len(lines) != 7 || lines[1] != "clock,1,3,1,0.3333,0.3333" {
+		t.Fatal(out.String())
+	}
+	out.Reset()
+	if err = run([]string{"-policies", "lru"}, strings.NewReader("a\na\n"), &out); err != nil || !strings.Contains(out.String(), "50.00%") {
+		t.Fatal(out.String(), err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// access 访问记录中的一次访问
+type access struct {
+	key  string
+	size int64 // 数据大小, 没有时为1
+}
+
+const (
+	formatAuto  = "auto"
+	formatPlain = "plain"
+	formatCSV   = "csv"
+)
+
+// readTrace 读取访问记录
+// plain: 每行一个key, 忽略空行; csv: timestamp,key[,size], 第一行的timestamp无法解析或size不是数字时视为表头
+// auto: 第一行包含逗号时按csv读取, 否则按plain读取
+func readTrace(r io.Reader, format string) ([]access, error) {
+	br := bufio.NewReader(r)
+	if format == formatAuto {
+		first, err := br.Peek(4096)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		line := string(first)
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		format = formatPlain
+		if strings.Contains(line, ",") {
+			format = formatCSV
+		}
+	}
+	switch format {
+	case formatPlain:
+		return readPlain(br)
+	case formatCSV:
+		return readCSV(br)
+	default:
+		return nil, fmt.Errorf("unknown trace format %q", format)
+	}
+}
+
+func readPlain(r io.Reader) ([]access, error) {
+	var trace []access
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			trace = append(trace, access{key: key, size: 1})
+		}
+	}
+	return trace, scanner.Err()
+}
+
+func readCSV(r io.Reader) ([]access, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	var trace []access
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return trace, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: want timestamp,key[,size], got %d fields", line, len(record))
+		}
+		if line == 1 && !validTimestamp(record[0]) {
+			continue // 表头
+		}
+		a := access{key: record[1], size: 1}
+		if len(record) >= 3 {
+			size, err := strconv.ParseInt(strings.TrimSpace(record[2]), 10, 64)
+			if err != nil || size < 0 {
+				if line == 1 {
+					continue // 表头
+				}
+				return nil, fmt.Errorf("line %d: invalid size %q", line, record[2])
+			}
+			a.size = size
+		}
+		trace = append(trace, a)
+	}
+}
+
+// validTimestamp 时间戳只用来识别表头, 支持数字(如unix时间)和 RFC 3339 格式
+func validTimestamp(field string) bool {
+	field = strings.TrimSpace(field)
+	if _, err := strconv.ParseFloat(field, 64); err == nil {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339Nano, field)
+	return err == nil
+}
@@ -0,0 +1,52 @@
+package concurrency
+
+import (
+	"errors"
+	"sync"
+)
+
+var errFlightPanicked = errors.New("single flight function panicked")
+
+// flightCall 一次正在进行中的调用
+type flightCall[T any] struct {
+	wg   sync.WaitGroup
+	val  *T
+	err  error
+	dups int // 等待共享结果的调用方数量
+}
+
+// SingleFlight 合并同一个key的并发调用, 同一时刻每个key只会执行一次fn, 其他调用方等待并共享结果
+// 零值可以直接使用
+type SingleFlight[T any] struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+// Do 执行fn并返回结果, 如果该key已经有调用在进行中, 等待其完成并返回同一个结果
+// shared 表示结果是否与其他调用方共享. fn panic时, 等待中的调用方会收到error
+func (g *SingleFlight[T]) Do(key string, fn func() (*T, error)) (val *T, err error, shared bool) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if call, ok := g.calls[key]; ok {
+		call.dups++
+		g.mutex.Unlock()
+		call.wg.Wait()
+		return call.val, call.err, true
+	}
+	call := &flightCall[T]{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		call.wg.Done()
+	}()
+	call.err = errFlightPanicked
+	call.val, call.err = fn()
+	return call.val, call.err, false
+}
@@ -0,0 +1,62 @@
+package concurrency
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSingleFlight(t *testing.T) {
+	var g SingleFlight[int]
+	var calls int32
+	var wg sync.WaitGroup
+	shared := int32(0)
+	// 其余9个调用方都进入Do并开始等待之后fn才返回
+	entered := make(chan struct{})
+	go func() {
+		for {
+			g.mutex.Lock()
+			call := g.calls["k"]
+			dups := 0
+			if call != nil {
+				dups = call.dups
+			}
+			g.mutex.Unlock()
+			if dups == 9 {
+				close(entered)
+				return
+			}
+			runtime.Gosched()
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err, isShared := g.Do("k", func() (*int, error) {
+				atomic.AddInt32(&calls, 1)
+				<-entered
+				v := 1
+				return &v, nil
+			})
+			if err != nil || *val != 1 {
+				t.Errorf("val=%v, err=%v", val, err)
+			}
+			if isShared {
+				atomic.AddInt32(&shared, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 || shared != 9 {
+		t.Fatalf("calls=%d, shared=%d", calls, shared)
+	}
+
+	// 调用结束后再次调用会重新执行
+	wantErr := errors.New("boom")
+	if _, err, _ := g.Do("k", func() (*int, error) { return nil, wantErr }); err != wantErr {
+		t.Fatalf("err=%v, want %v", err, wantErr)
+	}
+}